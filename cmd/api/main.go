@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"net/http"
@@ -15,6 +16,15 @@ import (
 )
 
 func main() {
+	// Manage the database schema with "api migrate <command>"
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		if err := runMigrate(os.Args[2:]); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+
 	// Initialize logger
 	logger := log.New(os.Stdout, "API: ", log.LstdFlags|log.Lshortfile)
 	logger.Println("Starting Bike Parts Finder API server")
@@ -26,13 +36,22 @@ func main() {
 	}
 	defer db.Close()
 
+	// Bring the schema up to date unless migrations are run separately
+	if database.AutoMigrate() {
+		if err := db.Migrate(context.Background()); err != nil {
+			logger.Fatalf("Failed to migrate database: %v", err)
+		}
+	}
+
 	// Initialize Redis cache
+	var partCache cache.PartCache
 	cacheClient, err := cache.NewRedisClient()
 	if err != nil {
 		logger.Printf("Warning: Failed to connect to Redis: %v", err)
 		// Continue without cache
 	} else {
 		defer cacheClient.Close()
+		partCache = cacheClient
 	}
 
 	// Initialize router with strict slashes
@@ -43,7 +62,9 @@ func main() {
 	router.Use(middleware.CORS)
 
 	// Initialize handlers
-	partHandler := handlers.NewPartHandler(db, cacheClient)
+	partHandler := handlers.NewPartHandler(db, partCache)
+	categoryHandler := handlers.NewCategoryHandler(db)
+	alertHandler := handlers.NewAlertHandler(db, db)
 
 	// Health check endpoints
 	router.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
@@ -72,16 +93,8 @@ func main() {
 		fmt.Fprintf(w, "Ready")
 	}).Methods("GET")
 
-	// API v1 Routes
-	apiV1 := router.PathPrefix("/api/v1").Subrouter()
-	apiV1.HandleFunc("/parts", partHandler.GetAllParts).Methods("GET")
-	apiV1.HandleFunc("/parts/{id}", partHandler.GetPartByID).Methods("GET")
-	apiV1.HandleFunc("/parts/search", partHandler.SearchParts).Methods("GET")
-
-	// For backward compatibility
-	router.HandleFunc("/api/parts", partHandler.GetAllParts).Methods("GET")
-	router.HandleFunc("/api/parts/{id}", partHandler.GetPartByID).Methods("GET")
-	router.HandleFunc("/api/parts/search", partHandler.SearchParts).Methods("GET")
+	// API routes
+	registerRoutes(router, partHandler, categoryHandler, alertHandler)
 
 	// Serve WebAssembly content
 	fs := http.FileServer(http.Dir("./web/dist"))
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/sosadtsia/bike-parts-finder/pkg/database"
+)
+
+// migrateUsage describes the migrate subcommand
+const migrateUsage = `usage: api migrate <command>
+
+commands:
+  up           apply all pending migrations
+  down [n]     revert the last n applied migrations (default 1)
+  status       list migrations and when they were applied
+  seed <file>  run a SQL file, e.g. scripts/sample-data.sql`
+
+// runMigrate runs the migrate subcommand with its arguments
+func runMigrate(args []string) error {
+	logger := log.New(os.Stdout, "MIGRATE: ", log.LstdFlags)
+	if len(args) == 0 {
+		return fmt.Errorf("missing command\n%s", migrateUsage)
+	}
+
+	ctx := context.Background()
+	db, err := database.NewPostgresClient()
+	if err != nil {
+		return fmt.Errorf("connecting to database: %w", err)
+	}
+	defer db.Close()
+
+	migrator, err := db.NewMigrator()
+	if err != nil {
+		return err
+	}
+	migrator.Logf = logger.Printf
+
+	switch args[0] {
+	case "up":
+		n, err := migrator.Up(ctx)
+		if err != nil {
+			return err
+		}
+		logger.Printf("Applied %d migrations", n)
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			if steps, err = strconv.Atoi(args[1]); err != nil || steps < 1 {
+				return fmt.Errorf("invalid number of migrations %q", args[1])
+			}
+		}
+		n, err := migrator.Down(ctx, steps)
+		if err != nil {
+			return err
+		}
+		logger.Printf("Reverted %d migrations", n)
+	case "status":
+		statuses, err := migrator.Status(ctx)
+		for _, s := range statuses {
+			applied := "pending"
+			if s.AppliedAt != nil {
+				applied = "applied " + s.AppliedAt.Format(time.RFC3339)
+			}
+			fmt.Printf("%04d_%s\t%s\n", s.Version, s.Name, applied)
+		}
+		if err != nil {
+			return err
+		}
+	case "seed":
+		if len(args) < 2 {
+			return fmt.Errorf("missing SQL file\n%s", migrateUsage)
+		}
+		sql, err := os.ReadFile(args[1])
+		if err != nil {
+			return fmt.Errorf("reading %s: %w", args[1], err)
+		}
+		if err := db.ExecScript(ctx, string(sql)); err != nil {
+			return fmt.Errorf("running %s: %w", args[1], err)
+		}
+		logger.Printf("Ran %s", args[1])
+	default:
+		return fmt.Errorf("unknown command %q\n%s", args[0], migrateUsage)
+	}
+
+	return nil
+}
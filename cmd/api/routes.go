@@ -0,0 +1,29 @@
+package main
+
+import (
+	"github.com/gorilla/mux"
+	"github.com/sosadtsia/bike-parts-finder/pkg/api/handlers"
+)
+
+// registerRoutes adds the API routes to a router. mux matches routes in the
+// order they are added, so fixed paths such as /parts/search come before
+// the /parts/{id} routes that would take them for an ID.
+func registerRoutes(router *mux.Router, partHandler *handlers.PartHandler, categoryHandler *handlers.CategoryHandler, alertHandler *handlers.AlertHandler) {
+	// API v1 Routes
+	apiV1 := router.PathPrefix("/api/v1").Subrouter()
+	apiV1.HandleFunc("/parts", partHandler.GetAllParts).Methods("GET")
+	apiV1.HandleFunc("/parts/search", partHandler.SearchParts).Methods("GET")
+	apiV1.HandleFunc("/parts/{id}", partHandler.GetPartByID).Methods("GET")
+	apiV1.HandleFunc("/parts/{id}/price-history", partHandler.GetPriceHistory).Methods("GET")
+	apiV1.HandleFunc("/parts/{id}/alerts", alertHandler.GetAlerts).Methods("GET")
+	apiV1.HandleFunc("/parts/{id}/alerts", alertHandler.CreateAlert).Methods("POST")
+	apiV1.HandleFunc("/alerts/{id}", alertHandler.DeleteAlert).Methods("DELETE")
+	apiV1.HandleFunc("/alerts/{id}/deliveries", alertHandler.GetAlertDeliveries).Methods("GET")
+	apiV1.HandleFunc("/categories", categoryHandler.GetCategories).Methods("GET")
+	apiV1.HandleFunc("/categories/unmapped", categoryHandler.GetUnmappedCategories).Methods("GET")
+
+	// For backward compatibility, with search results as a plain list
+	router.HandleFunc("/api/parts", partHandler.GetAllParts).Methods("GET")
+	router.HandleFunc("/api/parts/search", partHandler.SearchPartsList).Methods("GET")
+	router.HandleFunc("/api/parts/{id}", partHandler.GetPartByID).Methods("GET")
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/sosadtsia/bike-parts-finder/pkg/api/handlers"
+	"github.com/sosadtsia/bike-parts-finder/pkg/database"
+	"github.com/sosadtsia/bike-parts-finder/pkg/models"
+)
+
+func newTestRouter(t *testing.T) *mux.Router {
+	t.Helper()
+	repo := database.NewMemoryPartRepository()
+	part := models.Part{ID: "xt-brake", Brand: "Shimano", Model: "XT Brake", Category: "brakes", Source: "test", Price: 129.99}
+	if err := repo.StorePart(context.Background(), part); err != nil {
+		t.Fatal(err)
+	}
+
+	router := mux.NewRouter().StrictSlash(true)
+	registerRoutes(router, handlers.NewPartHandler(repo, nil), handlers.NewCategoryHandler(nil), handlers.NewAlertHandler(repo, nil))
+	return router
+}
+
+func TestSearchRoutes(t *testing.T) {
+	router := newTestRouter(t)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/parts/search?q=shimano", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", w.Code, w.Body)
+	}
+	var response struct {
+		Parts []models.Part `json:"parts"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatalf("decoding response: %v", err)
+	}
+	if len(response.Parts) != 1 || response.Parts[0].ID != "xt-brake" {
+		t.Errorf("got parts %+v, want xt-brake", response.Parts)
+	}
+}
+
+func TestLegacySearchRoute(t *testing.T) {
+	router := newTestRouter(t)
+
+	// The unversioned route keeps returning a plain list
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/parts/search?q=shimano", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", w.Code, w.Body)
+	}
+	var parts []models.Part
+	if err := json.Unmarshal(w.Body.Bytes(), &parts); err != nil {
+		t.Fatalf("decoding response: %v", err)
+	}
+	if len(parts) != 1 || parts[0].ID != "xt-brake" {
+		t.Errorf("got parts %+v, want xt-brake", parts)
+	}
+}
+
+func TestPartRoutes(t *testing.T) {
+	router := newTestRouter(t)
+
+	tests := []struct {
+		path string
+		code int
+	}{
+		{"/api/v1/parts/xt-brake", http.StatusOK},
+		{"/api/parts/xt-brake", http.StatusOK},
+		{"/api/v1/parts/missing", http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tt.path, nil))
+		if w.Code != tt.code {
+			t.Errorf("GET %s: status %d, want %d", tt.path, w.Code, tt.code)
+		}
+	}
+}
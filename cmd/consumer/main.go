@@ -3,15 +3,42 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"log"
 	"os"
 	"os/signal"
 	"syscall"
 	"time"
 
+	"github.com/sosadtsia/bike-parts-finder/pkg/alerts"
 	"github.com/sosadtsia/bike-parts-finder/pkg/database"
 	"github.com/sosadtsia/bike-parts-finder/pkg/kafka"
 	"github.com/sosadtsia/bike-parts-finder/pkg/models"
+	"github.com/sosadtsia/bike-parts-finder/pkg/taxonomy"
+)
+
+// categoryRefreshInterval is how often category mapping rules are reloaded
+const categoryRefreshInterval = 5 * time.Minute
+
+// priceHistoryPruneInterval is how often old price observations are
+// downsampled and deleted
+const priceHistoryPruneInterval = time.Hour
+
+// Alert delivery settings: how often due deliveries are sent, how many at
+// a time, and how long a claimed delivery is held before another consumer
+// may retry it
+const (
+	alertDispatchInterval = 10 * time.Second
+	alertDispatchBatch    = 50
+	alertDeliveryLease    = 5 * time.Minute
+)
+
+// Default ages after which price observations are downsampled to one per
+// day and deleted
+const (
+	defaultPriceHistoryDownsampleAfter = 90 * 24 * time.Hour
+	defaultPriceHistoryRetention       = 2 * 365 * 24 * time.Hour
 )
 
 func main() {
@@ -37,6 +64,30 @@ func main() {
 	}
 	defer db.Close()
 
+	// Bring the schema up to date unless migrations are run separately
+	if database.AutoMigrate() {
+		if err := db.Migrate(ctx); err != nil {
+			logger.Fatalf("Failed to migrate database: %v", err)
+		}
+	}
+
+	// Load the category taxonomy and its mapping rules, which are reloaded
+	// periodically so new rules apply without a restart
+	mapper, err := loadCategoryMapper(ctx, db)
+	if err != nil {
+		logger.Fatalf("Failed to load category mappings: %v", err)
+	}
+	mapperLoaded := time.Now()
+
+	downsampleAfter, retention, err := priceHistoryRetentionFromEnv()
+	if err != nil {
+		logger.Fatalf("Invalid price history configuration: %v", err)
+	}
+	var lastPrune time.Time
+
+	// Deliver alert webhooks in the background
+	go dispatchAlerts(ctx, db, alerts.NewNotifier(), logger)
+
 	// Initialize Kafka consumer for scrape results
 	consumer, err := kafka.NewConsumer("scrape_results")
 	if err != nil {
@@ -67,16 +118,56 @@ func main() {
 			}
 
 			logger.Printf("Received %d parts from scrape of %s", len(result.Parts), result.URL)
+			if len(result.Errors) > 0 {
+				logger.Printf("Scrape of %s reported %d failed pages", result.URL, len(result.Errors))
+			}
 
-			// Store parts in database
-			for _, part := range result.Parts {
-				if err := db.StorePart(ctx, part); err != nil {
-					logger.Printf("Error storing part %s: %v", part.ID, err)
-					continue
+			if time.Since(mapperLoaded) > categoryRefreshInterval {
+				if m, err := loadCategoryMapper(ctx, db); err != nil {
+					logger.Printf("Error reloading category mappings: %v", err)
+				} else {
+					mapper = m
 				}
+				mapperLoaded = time.Now()
 			}
 
-			logger.Printf("Successfully stored %d parts from %s", len(result.Parts), result.URL)
+			if time.Since(lastPrune) > priceHistoryPruneInterval {
+				now := time.Now()
+				if n, err := db.PrunePriceObservations(ctx, now.Add(-downsampleAfter), now.Add(-retention)); err != nil {
+					logger.Printf("Error pruning price history: %v", err)
+				} else if n > 0 {
+					logger.Printf("Pruned %d price observations", n)
+				}
+				lastPrune = now
+			}
+
+			// Map the retailers' breadcrumbs to the taxonomy
+			var unmapped []models.UnmappedCategory
+			for i := range result.Parts {
+				part := &result.Parts[i]
+				if !mapper.Apply(part) && len(part.Breadcrumbs) > 0 {
+					unmapped = append(unmapped, models.UnmappedCategory{
+						Source: part.Source, Breadcrumbs: part.Breadcrumbs, Occurrences: 1, ExampleURL: part.URL,
+					})
+				}
+			}
+			if err := db.RecordUnmappedCategories(ctx, unmapped); err != nil {
+				logger.Printf("Error recording %d unmapped categories from %s: %v", len(unmapped), result.URL, err)
+			}
+
+			// Store parts in database
+			stored := storeParts(ctx, db, result.Parts, logger)
+
+			// Record the prices and stock in the price history if they changed
+			if _, err := db.RecordPriceObservations(ctx, stored); err != nil {
+				logger.Printf("Error recording prices from %s: %v", result.URL, err)
+			}
+
+			if err := evaluateAlerts(ctx, db, stored); err != nil {
+				logger.Printf("Error evaluating alerts for %s: %v", result.URL, err)
+			}
+
+			logger.Printf("Successfully stored %d of %d parts from %s", len(stored), len(result.Parts), result.URL)
 
 			// Commit the message offset
 			if err := consumer.CommitMessage(msg); err != nil {
@@ -85,3 +176,142 @@ func main() {
 		}
 	}
 }
+
+// storeParts stores the parts of a scrape result in one go, falling back to
+// storing them one by one if that fails so that a single bad part does not
+// lose the rest. It returns the parts that were stored.
+func storeParts(ctx context.Context, db *database.PostgresClient, parts []models.Part, logger *log.Logger) []models.Part {
+	err := db.StoreParts(ctx, parts)
+	if err == nil {
+		return parts
+	}
+	logger.Printf("Error storing %d parts, storing them one by one: %v", len(parts), err)
+
+	var stored []models.Part
+	for _, part := range parts {
+		if err := db.StorePart(ctx, part); err != nil {
+			logger.Printf("Error storing part %s: %v", part.ID, err)
+			continue
+		}
+		stored = append(stored, part)
+	}
+	return stored
+}
+
+// loadCategoryMapper builds a category mapper from the taxonomy and mapping
+// rules in the database
+func loadCategoryMapper(ctx context.Context, db *database.PostgresClient) (*taxonomy.Mapper, error) {
+	categories, err := db.GetCategories(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("loading categories: %w", err)
+	}
+	mappings, err := db.GetCategoryMappings(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("loading category mappings: %w", err)
+	}
+	return taxonomy.NewMapper(categories, mappings), nil
+}
+
+// evaluateAlerts evaluates the alert rules of newly stored parts, queuing
+// a webhook delivery for each rule that fires
+func evaluateAlerts(ctx context.Context, db *database.PostgresClient, parts []models.Part) error {
+	if len(parts) == 0 {
+		return nil
+	}
+
+	byID := make(map[string]models.Part, len(parts))
+	ids := make([]string, 0, len(parts))
+	for _, part := range parts {
+		byID[part.ID] = part
+		ids = append(ids, part.ID)
+	}
+
+	rules, err := db.GetAlertRulesForParts(ctx, ids)
+	if err != nil {
+		return fmt.Errorf("loading alert rules: %w", err)
+	}
+
+	now := time.Now()
+	for _, rule := range rules {
+		part := byID[rule.PartID]
+		fire, triggered := alerts.Evaluate(rule, part)
+		switch {
+		case fire:
+			if err := db.TriggerAlert(ctx, alerts.NewEvent(rule, part, now)); err != nil {
+				return fmt.Errorf("triggering alert %s: %w", rule.ID, err)
+			}
+		case rule.Triggered && !triggered:
+			if err := db.RearmAlert(ctx, rule.ID); err != nil {
+				return fmt.Errorf("re-arming alert %s: %w", rule.ID, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// dispatchAlerts periodically sends due alert deliveries to their webhooks
+// until ctx is done. Failed deliveries are retried with backoff and marked
+// failed after alerts.MaxAttempts attempts, or at once if the webhook
+// resolves to a private or reserved address.
+func dispatchAlerts(ctx context.Context, db *database.PostgresClient, notifier *alerts.Notifier, logger *log.Logger) {
+	ticker := time.NewTicker(alertDispatchInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		deliveries, err := db.ClaimAlertDeliveries(ctx, alertDispatchBatch, alertDeliveryLease)
+		if err != nil {
+			logger.Printf("Error claiming alert deliveries: %v", err)
+			continue
+		}
+
+		for _, d := range deliveries {
+			status, next := models.DeliveryDelivered, time.Now()
+			code, sendErr := notifier.Send(ctx, d.WebhookURL, d.Secret, d.Payload)
+			if sendErr != nil {
+				status = models.DeliveryPending
+				next = time.Now().Add(alerts.RetryDelay(d.Attempts + 1))
+				if d.Attempts+1 >= alerts.MaxAttempts || errors.Is(sendErr, alerts.ErrForbiddenAddress) {
+					status = models.DeliveryFailed
+				}
+				logger.Printf("Error delivering alert %s to %s (attempt %d): %v", d.AlertID, d.WebhookURL, d.Attempts+1, sendErr)
+			}
+			if err := db.RecordAlertDeliveryAttempt(ctx, d.ID, status, code, sendErr, next); err != nil {
+				logger.Printf("Error recording delivery %d of alert %s: %v", d.ID, d.AlertID, err)
+			}
+		}
+	}
+}
+
+// priceHistoryRetentionFromEnv returns the ages after which price
+// observations are downsampled and deleted, configured with
+// PRICE_HISTORY_DOWNSAMPLE_AFTER and PRICE_HISTORY_RETENTION
+func priceHistoryRetentionFromEnv() (time.Duration, time.Duration, error) {
+	downsampleAfter, retention := defaultPriceHistoryDownsampleAfter, defaultPriceHistoryRetention
+
+	if v := os.Getenv("PRICE_HISTORY_DOWNSAMPLE_AFTER"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return 0, 0, fmt.Errorf("parsing PRICE_HISTORY_DOWNSAMPLE_AFTER: %w", err)
+		}
+		downsampleAfter = d
+	}
+	if v := os.Getenv("PRICE_HISTORY_RETENTION"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return 0, 0, fmt.Errorf("parsing PRICE_HISTORY_RETENTION: %w", err)
+		}
+		retention = d
+	}
+	if retention < downsampleAfter {
+		return 0, 0, fmt.Errorf("PRICE_HISTORY_RETENTION %s is shorter than PRICE_HISTORY_DOWNSAMPLE_AFTER %s", retention, downsampleAfter)
+	}
+
+	return downsampleAfter, retention, nil
+}
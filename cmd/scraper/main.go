@@ -6,6 +6,7 @@ import (
 	"log"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
 	"time"
 
@@ -42,8 +43,24 @@ func main() {
 		logger.Fatalf("Failed to initialize Kafka producer: %v", err)
 	}
 
-	// Initialize scrapers
-	jensonScraper := scraping.NewJensonUSAScraper()
+	// Apply the crawl politeness policy shared by all scrapers
+	politeness, err := scraping.NewPolitenessFromEnv()
+	if err != nil {
+		logger.Fatalf("Failed to configure scraper politeness: %v", err)
+	}
+	scraping.SetPoliteness(politeness)
+	logger.Printf("Crawling as %q with a %s delay per domain", politeness.UserAgent, politeness.Delay)
+
+	// Initialize scrapers from the retailer definitions and built-in scrapers
+	registry, err := loadRegistry()
+	if err != nil {
+		logger.Fatalf("Failed to initialize scrapers: %v", err)
+	}
+	logger.Printf("Enabled scrapers: %s", strings.Join(registry.Sources(), ", "))
+
+	// Reload the retailer definitions on SIGHUP
+	reload := make(chan os.Signal, 1)
+	signal.Notify(reload, syscall.SIGHUP)
 
 	// Process scrape requests
 	for {
@@ -53,6 +70,14 @@ func main() {
 			consumer.Close()
 			producer.Close()
 			return
+		case <-reload:
+			reloaded, err := loadRegistry()
+			if err != nil {
+				logger.Printf("Error reloading scrapers, keeping current configuration: %v", err)
+				continue
+			}
+			registry = reloaded
+			logger.Printf("Reloaded scrapers: %s", strings.Join(registry.Sources(), ", "))
 		default:
 			// Poll for new messages
 			msg, err := consumer.ReadMessage(100 * time.Millisecond)
@@ -72,28 +97,26 @@ func main() {
 			logger.Printf("Received scrape request for: %s", request.URL)
 
 			// Select the appropriate scraper
-			var parts []models.Part
-
-			if jensonScraper.CanHandle(request.URL) {
-				parts, err = jensonScraper.Scrape(request.URL)
-			} else {
+			scraper, ok := registry.ScraperFor(request.URL)
+			if !ok {
 				logger.Printf("No scraper available for URL: %s", request.URL)
 				continue
 			}
 
+			result, err := scraper.Scrape(ctx, request)
 			if err != nil {
 				logger.Printf("Error scraping %s: %v", request.URL, err)
 				continue
 			}
-
-			// Send results to Kafka
-			result := models.ScrapeResult{
-				RequestID: request.ID,
-				URL:       request.URL,
-				Parts:     parts,
-				Timestamp: time.Now(),
+			if result.Truncated {
+				logger.Printf("Scrape of %s was truncated after %d parts", request.URL, len(result.Parts))
+			}
+			for _, scrapeErr := range result.Errors {
+				logger.Printf("Failed to scrape %s (%s after %d attempts): %s",
+					scrapeErr.URL, scrapeErr.Kind, scrapeErr.Attempts, scrapeErr.Message)
 			}
 
+			// Send results to Kafka
 			resultBytes, err := json.Marshal(result)
 			if err != nil {
 				logger.Printf("Error serializing scrape result: %v", err)
@@ -103,8 +126,17 @@ func main() {
 			if err := producer.WriteMessage(resultBytes); err != nil {
 				logger.Printf("Error sending scrape result to Kafka: %v", err)
 			} else {
-				logger.Printf("Successfully scraped %d parts from %s", len(parts), request.URL)
+				logger.Printf("Successfully scraped %d parts from %s", len(result.Parts), request.URL)
 			}
 		}
 	}
 }
+
+// loadRegistry creates the scraper registry from SCRAPER_CONFIG_DIR,
+// optionally limited to the comma-separated sources in SCRAPER_SOURCES
+func loadRegistry() (*scraping.Registry, error) {
+	return scraping.NewConfiguredRegistry(
+		os.Getenv("SCRAPER_CONFIG_DIR"),
+		strings.Split(os.Getenv("SCRAPER_SOURCES"), ",")...,
+	)
+}
@@ -0,0 +1,47 @@
+package alerts
+
+import (
+	"time"
+
+	"github.com/sosadtsia/bike-parts-finder/pkg/models"
+)
+
+// Condition reports whether a part meets the condition of a rule
+func Condition(rule models.AlertRule, part models.Part) bool {
+	switch rule.Type {
+	case models.AlertPriceBelow:
+		return part.Price > 0 && part.Price <= rule.Threshold
+	case models.AlertPriceDrop:
+		return part.Price > 0 && rule.BaselinePrice > 0 &&
+			part.Price <= rule.BaselinePrice*(1-rule.DropPercent/100)
+	case models.AlertBackInStock:
+		return part.InStock
+	}
+	return false
+}
+
+// Evaluate evaluates a rule against a newly scraped part. It reports
+// whether the rule fires and its new triggered state: a rule fires when
+// its condition becomes true and is re-armed once the condition is false.
+func Evaluate(rule models.AlertRule, part models.Part) (fire, triggered bool) {
+	met := Condition(rule, part)
+	return met && !rule.Triggered, met
+}
+
+// NewEvent returns the event sent when a rule fires for a part
+func NewEvent(rule models.AlertRule, part models.Part, now time.Time) models.AlertEvent {
+	return models.AlertEvent{
+		AlertID:       rule.ID,
+		Type:          rule.Type,
+		PartID:        part.ID,
+		Brand:         part.Brand,
+		Model:         part.Model,
+		URL:           part.URL,
+		Price:         part.Price,
+		Currency:      part.Currency,
+		InStock:       part.InStock,
+		Threshold:     rule.Threshold,
+		BaselinePrice: rule.BaselinePrice,
+		TriggeredAt:   now.UTC(),
+	}
+}
@@ -0,0 +1,171 @@
+package alerts
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// Webhook request headers
+const (
+	SignatureHeader = "X-Alert-Signature"
+	TimestampHeader = "X-Alert-Timestamp"
+)
+
+// MaxAttempts is the number of times a delivery is attempted before it is
+// marked failed
+const MaxAttempts = 6
+
+// Backoff bounds between delivery attempts
+const (
+	minRetryDelay = 30 * time.Second
+	maxRetryDelay = time.Hour
+)
+
+// ErrForbiddenAddress is returned for webhooks on loopback, private,
+// link-local or other reserved addresses, which users must not be able to
+// make the service post to
+var ErrForbiddenAddress = errors.New("webhook address is private or reserved")
+
+// reservedPrefixes are the special-purpose networks webhooks may not be on
+// besides those netip.Addr reports as private, loopback, link-local,
+// multicast or unspecified
+var reservedPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),
+	netip.MustParsePrefix("100.64.0.0/10"),
+	netip.MustParsePrefix("192.0.0.0/24"),
+	netip.MustParsePrefix("192.0.2.0/24"),
+	netip.MustParsePrefix("198.18.0.0/15"),
+	netip.MustParsePrefix("198.51.100.0/24"),
+	netip.MustParsePrefix("203.0.113.0/24"),
+	netip.MustParsePrefix("240.0.0.0/4"),
+	netip.MustParsePrefix("64:ff9b::/96"),
+	netip.MustParsePrefix("100::/64"),
+	netip.MustParsePrefix("2001:db8::/32"),
+}
+
+// PublicAddress reports whether an IP address is publicly routable and so
+// allowed for webhooks
+func PublicAddress(ip netip.Addr) bool {
+	ip = ip.Unmap()
+	if !ip.IsValid() || ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsInterfaceLocalMulticast() || ip.IsMulticast() {
+		return false
+	}
+	for _, prefix := range reservedPrefixes {
+		if prefix.Contains(ip) {
+			return false
+		}
+	}
+	return true
+}
+
+// ValidateWebhookURL checks that a webhook URL is an absolute http or https
+// URL whose host is not a private or reserved IP address or localhost.
+// Host names are only resolved when deliveries are sent.
+func ValidateWebhookURL(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Hostname() == "" {
+		return errors.New("must be an absolute http or https URL")
+	}
+	host := strings.TrimSuffix(strings.ToLower(u.Hostname()), ".")
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return ErrForbiddenAddress
+	}
+	if ip, err := netip.ParseAddr(host); err == nil && !PublicAddress(ip) {
+		return ErrForbiddenAddress
+	}
+	return nil
+}
+
+// Notifier delivers alert events to webhooks
+type Notifier struct {
+	client *http.Client
+}
+
+// NewNotifier creates a new webhook notifier. It only connects to public
+// addresses, checked after host names are resolved so that names pointing
+// at internal services, or redirects to them, are refused too.
+func NewNotifier() *Notifier {
+	dialer := &net.Dialer{
+		Timeout: 10 * time.Second,
+		Control: func(network, address string, c syscall.RawConn) error {
+			addrPort, err := netip.ParseAddrPort(address)
+			if err != nil || !PublicAddress(addrPort.Addr()) {
+				return ErrForbiddenAddress
+			}
+			return nil
+		},
+	}
+	return &Notifier{
+		client: &http.Client{
+			Timeout: 10 * time.Second,
+			// No proxy, which would connect on the webhook's behalf
+			Transport: &http.Transport{
+				DialContext:         dialer.DialContext,
+				TLSHandshakeTimeout: 10 * time.Second,
+				MaxIdleConns:        10,
+				IdleConnTimeout:     90 * time.Second,
+			},
+		},
+	}
+}
+
+// Send posts a JSON payload to a webhook, signed with its secret. It
+// returns the response status code, and an error unless the webhook
+// answered with a 2xx status.
+func (n *Notifier) Send(ctx context.Context, url, secret string, payload []byte) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(payload))
+	if err != nil {
+		return 0, fmt.Errorf("creating webhook request: %w", err)
+	}
+
+	timestamp := time.Now().Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(TimestampHeader, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(SignatureHeader, "sha256="+Sign(secret, timestamp, payload))
+
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("posting webhook: %w", err)
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("webhook answered %s", resp.Status)
+	}
+	return resp.StatusCode, nil
+}
+
+// Sign returns the hex HMAC-SHA256 of "<timestamp>.<payload>" keyed with
+// the webhook secret, which receivers recompute to verify a request
+func Sign(secret string, timestamp int64, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10) + "."))
+	mac.Write(payload)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// RetryDelay returns how long to wait before retrying a delivery that has
+// failed attempts times, doubling from 30 seconds up to an hour
+func RetryDelay(attempts int) time.Duration {
+	delay := minRetryDelay
+	for i := 1; i < attempts && delay < maxRetryDelay; i++ {
+		delay *= 2
+	}
+	return min(delay, maxRetryDelay)
+}
@@ -0,0 +1,102 @@
+package alerts
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+)
+
+func TestPublicAddress(t *testing.T) {
+	tests := []struct {
+		ip     string
+		public bool
+	}{
+		{"93.184.216.34", true},
+		{"2606:2800:220:1:248:1893:25c8:1946", true},
+		{"127.0.0.1", false},
+		{"::1", false},
+		{"10.1.2.3", false},
+		{"172.16.0.1", false},
+		{"192.168.1.1", false},
+		{"169.254.169.254", false},
+		{"fe80::1", false},
+		{"fd00::1", false},
+		{"100.64.0.1", false},
+		{"0.0.0.0", false},
+		{"224.0.0.1", false},
+		{"255.255.255.255", false},
+		{"::ffff:127.0.0.1", false},
+		{"::ffff:169.254.169.254", false},
+	}
+	for _, tt := range tests {
+		if got := PublicAddress(netip.MustParseAddr(tt.ip)); got != tt.public {
+			t.Errorf("PublicAddress(%s) = %t, want %t", tt.ip, got, tt.public)
+		}
+	}
+}
+
+func TestValidateWebhookURL(t *testing.T) {
+	tests := []struct {
+		url string
+		err error
+	}{
+		{"https://hooks.example.com/alerts", nil},
+		{"http://93.184.216.34:8080/alerts", nil},
+		{"http://169.254.169.254/latest/meta-data/", ErrForbiddenAddress},
+		{"http://127.0.0.1:9000/", ErrForbiddenAddress},
+		{"http://[::1]/", ErrForbiddenAddress},
+		{"http://10.0.0.5/", ErrForbiddenAddress},
+		{"http://localhost:8080/", ErrForbiddenAddress},
+		{"http://api.localhost./", ErrForbiddenAddress},
+	}
+	for _, tt := range tests {
+		if err := ValidateWebhookURL(tt.url); !errors.Is(err, tt.err) {
+			t.Errorf("ValidateWebhookURL(%s) = %v, want %v", tt.url, err, tt.err)
+		}
+	}
+
+	for _, url := range []string{"ftp://hooks.example.com", "/alerts", "https://", "hooks.example.com"} {
+		if err := ValidateWebhookURL(url); err == nil || errors.Is(err, ErrForbiddenAddress) {
+			t.Errorf("ValidateWebhookURL(%s) = %v, want an invalid URL error", url, err)
+		}
+	}
+}
+
+func TestSendRefusesPrivateAddresses(t *testing.T) {
+	called := false
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+	}))
+	defer srv.Close()
+
+	// The test server listens on loopback, like an internal service would
+	_, err := NewNotifier().Send(context.Background(), srv.URL, "secret", []byte(`{}`))
+	if !errors.Is(err, ErrForbiddenAddress) {
+		t.Errorf("got %v, want ErrForbiddenAddress", err)
+	}
+	if called {
+		t.Error("webhook on a loopback address was called")
+	}
+}
+
+func TestSendSignsPayload(t *testing.T) {
+	payload := []byte(`{"alert_id":"rule-1"}`)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get(SignatureHeader) == "" || r.Header.Get(TimestampHeader) == "" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer srv.Close()
+
+	// Skip the address check, which refuses the loopback test server
+	n := &Notifier{client: srv.Client()}
+	code, err := n.Send(context.Background(), srv.URL, "secret", payload)
+	if err != nil || code != http.StatusAccepted {
+		t.Errorf("got %d %v, want 202", code, err)
+	}
+}
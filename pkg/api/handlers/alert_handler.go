@@ -0,0 +1,250 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/sosadtsia/bike-parts-finder/pkg/alerts"
+	"github.com/sosadtsia/bike-parts-finder/pkg/database"
+	"github.com/sosadtsia/bike-parts-finder/pkg/models"
+)
+
+// maxDeliveries is the number of deliveries returned in a delivery log
+const maxDeliveries = 100
+
+// AlertHandler handles alert-related API requests
+type AlertHandler struct {
+	parts database.PartRepository
+	db    database.AlertRepository
+}
+
+// NewAlertHandler creates a new alert handler
+func NewAlertHandler(parts database.PartRepository, db database.AlertRepository) *AlertHandler {
+	return &AlertHandler{
+		parts: parts,
+		db:    db,
+	}
+}
+
+// createAlertRequest is the body of a request creating an alert rule
+type createAlertRequest struct {
+	Type        string  `json:"type"`
+	Threshold   float64 `json:"threshold"`
+	DropPercent float64 `json:"drop_percent"`
+	WebhookURL  string  `json:"webhook_url"`
+	Secret      string  `json:"secret"`
+}
+
+// CreateAlert creates an alert rule on a part. The webhook secret is
+// generated unless given and is only returned here.
+func (h *AlertHandler) CreateAlert(w http.ResponseWriter, r *http.Request) {
+	// Set headers
+	w.Header().Set("Content-Type", "application/json")
+
+	// Get path parameters
+	vars := mux.Vars(r)
+	partID := vars["id"]
+
+	var req createAlertRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	part, err := h.parts.GetPartByID(r.Context(), partID)
+	if errors.Is(err, database.ErrPartNotFound) {
+		http.Error(w, "Part not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Error fetching part", http.StatusInternalServerError)
+		return
+	}
+
+	rule := models.AlertRule{
+		ID:         uuid.NewString(),
+		PartID:     part.ID,
+		Type:       req.Type,
+		WebhookURL: req.WebhookURL,
+		Secret:     req.Secret,
+	}
+	switch req.Type {
+	case models.AlertPriceBelow:
+		if req.Threshold <= 0 {
+			http.Error(w, "Invalid threshold: must be a positive price", http.StatusBadRequest)
+			return
+		}
+		rule.Threshold = req.Threshold
+	case models.AlertPriceDrop:
+		if req.DropPercent <= 0 || req.DropPercent >= 100 {
+			http.Error(w, "Invalid drop_percent: must be between 0 and 100", http.StatusBadRequest)
+			return
+		}
+		if part.Price <= 0 {
+			http.Error(w, "Part has no price to measure a drop from", http.StatusBadRequest)
+			return
+		}
+		rule.DropPercent = req.DropPercent
+		rule.BaselinePrice = part.Price
+	case models.AlertBackInStock:
+		// Parts in stock now only fire after going out of stock
+		rule.Triggered = part.InStock
+	default:
+		http.Error(w, "Invalid type: must be price_below, price_drop or back_in_stock", http.StatusBadRequest)
+		return
+	}
+
+	if err := alerts.ValidateWebhookURL(req.WebhookURL); err != nil {
+		http.Error(w, "Invalid webhook_url: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if rule.Secret == "" {
+		secret := make([]byte, 32)
+		if _, err := rand.Read(secret); err != nil {
+			http.Error(w, "Error generating webhook secret", http.StatusInternalServerError)
+			return
+		}
+		rule.Secret = hex.EncodeToString(secret)
+	}
+
+	rule, err = h.db.CreateAlertRule(r.Context(), rule)
+	if err != nil {
+		http.Error(w, "Error creating alert", http.StatusInternalServerError)
+		return
+	}
+
+	// Return the rule, including its secret, as JSON
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(rule); err != nil {
+		http.Error(w, "Error encoding response", http.StatusInternalServerError)
+		return
+	}
+}
+
+// GetAlerts returns the alert rules of a part without their webhooks and
+// secrets, which only the creator of a rule knows
+func (h *AlertHandler) GetAlerts(w http.ResponseWriter, r *http.Request) {
+	// Set headers
+	w.Header().Set("Content-Type", "application/json")
+
+	// Get path parameters
+	vars := mux.Vars(r)
+	partID := vars["id"]
+
+	rules, err := h.db.GetAlertRules(r.Context(), partID)
+	if err != nil {
+		http.Error(w, "Error fetching alerts", http.StatusInternalServerError)
+		return
+	}
+
+	list := []models.AlertRule{}
+	for _, rule := range rules {
+		rule.WebhookURL = ""
+		rule.Secret = ""
+		list = append(list, rule)
+	}
+
+	// Return rules as JSON
+	if err := json.NewEncoder(w).Encode(list); err != nil {
+		http.Error(w, "Error encoding response", http.StatusInternalServerError)
+		return
+	}
+}
+
+// authorizeAlert fetches the alert rule named in the path and checks that
+// the request carries its secret as a bearer token. It writes an error
+// response and returns false otherwise.
+func (h *AlertHandler) authorizeAlert(w http.ResponseWriter, r *http.Request) (models.AlertRule, bool) {
+	// Get path parameters
+	vars := mux.Vars(r)
+	id := vars["id"]
+
+	secret, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok || secret == "" {
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		http.Error(w, "Missing alert secret", http.StatusUnauthorized)
+		return models.AlertRule{}, false
+	}
+
+	rule, err := h.db.GetAlertRule(r.Context(), id)
+	if errors.Is(err, database.ErrAlertNotFound) {
+		http.Error(w, "Alert not found", http.StatusNotFound)
+		return rule, false
+	}
+	if err != nil {
+		http.Error(w, "Error fetching alert", http.StatusInternalServerError)
+		return rule, false
+	}
+	if subtle.ConstantTimeCompare([]byte(secret), []byte(rule.Secret)) != 1 {
+		http.Error(w, "Invalid alert secret", http.StatusForbidden)
+		return rule, false
+	}
+
+	return rule, true
+}
+
+// DeleteAlert deletes an alert rule and its delivery log. The request must
+// carry the rule's secret.
+func (h *AlertHandler) DeleteAlert(w http.ResponseWriter, r *http.Request) {
+	rule, ok := h.authorizeAlert(w, r)
+	if !ok {
+		return
+	}
+
+	deleted, err := h.db.DeleteAlertRule(r.Context(), rule.ID)
+	if err != nil {
+		http.Error(w, "Error deleting alert", http.StatusInternalServerError)
+		return
+	}
+	if !deleted {
+		http.Error(w, "Alert not found", http.StatusNotFound)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GetAlertDeliveries returns the latest webhook deliveries of an alert
+// rule. The request must carry the rule's secret.
+func (h *AlertHandler) GetAlertDeliveries(w http.ResponseWriter, r *http.Request) {
+	rule, ok := h.authorizeAlert(w, r)
+	if !ok {
+		return
+	}
+
+	// Set headers
+	w.Header().Set("Content-Type", "application/json")
+
+	limit := maxDeliveries
+	if text := r.URL.Query().Get("limit"); text != "" {
+		n, err := strconv.Atoi(text)
+		if err != nil || n < 1 || n > maxDeliveries {
+			http.Error(w, "Invalid limit", http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+
+	deliveries, err := h.db.GetAlertDeliveries(r.Context(), rule.ID, limit)
+	if err != nil {
+		http.Error(w, "Error fetching deliveries", http.StatusInternalServerError)
+		return
+	}
+	if deliveries == nil {
+		deliveries = []models.AlertDelivery{}
+	}
+
+	// Return deliveries as JSON
+	if err := json.NewEncoder(w).Encode(deliveries); err != nil {
+		http.Error(w, "Error encoding response", http.StatusInternalServerError)
+		return
+	}
+}
@@ -0,0 +1,213 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/sosadtsia/bike-parts-finder/pkg/database"
+	"github.com/sosadtsia/bike-parts-finder/pkg/models"
+)
+
+// fakeAlertRepository keeps alert rules and deliveries in memory
+type fakeAlertRepository struct {
+	mu         sync.Mutex
+	rules      []models.AlertRule
+	deliveries []models.AlertDelivery
+}
+
+func (f *fakeAlertRepository) CreateAlertRule(ctx context.Context, rule models.AlertRule) (models.AlertRule, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	rule.CreatedAt = time.Now()
+	f.rules = append(f.rules, rule)
+	return rule, nil
+}
+
+func (f *fakeAlertRepository) GetAlertRule(ctx context.Context, id string) (models.AlertRule, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, rule := range f.rules {
+		if rule.ID == id {
+			return rule, nil
+		}
+	}
+	return models.AlertRule{}, database.ErrAlertNotFound
+}
+
+func (f *fakeAlertRepository) GetAlertRules(ctx context.Context, partID string) ([]models.AlertRule, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var rules []models.AlertRule
+	for _, rule := range f.rules {
+		if rule.PartID == partID {
+			rules = append(rules, rule)
+		}
+	}
+	return rules, nil
+}
+
+func (f *fakeAlertRepository) DeleteAlertRule(ctx context.Context, id string) (bool, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for i, rule := range f.rules {
+		if rule.ID == id {
+			f.rules = append(f.rules[:i], f.rules[i+1:]...)
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (f *fakeAlertRepository) GetAlertDeliveries(ctx context.Context, alertID string, limit int) ([]models.AlertDelivery, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var deliveries []models.AlertDelivery
+	for _, delivery := range f.deliveries {
+		if delivery.AlertID == alertID && len(deliveries) < limit {
+			deliveries = append(deliveries, delivery)
+		}
+	}
+	return deliveries, nil
+}
+
+// postAlert sends a request creating an alert on a part
+func postAlert(h *AlertHandler, partID, body string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(http.MethodPost, "/api/v1/parts/"+partID+"/alerts", strings.NewReader(body))
+	r = mux.SetURLVars(r, map[string]string{"id": partID})
+	w := httptest.NewRecorder()
+	h.CreateAlert(w, r)
+	return w
+}
+
+// serveAlert sends a request for an alert rule with its secret as a bearer
+// token, unless the secret is empty
+func serveAlert(handler http.HandlerFunc, method, target, id, secret string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(method, target, nil)
+	r = mux.SetURLVars(r, map[string]string{"id": id})
+	if secret != "" {
+		r.Header.Set("Authorization", "Bearer "+secret)
+	}
+	w := httptest.NewRecorder()
+	handler(w, r)
+	return w
+}
+
+func TestCreateAlert(t *testing.T) {
+	alerts := &fakeAlertRepository{}
+	h := NewAlertHandler(newTestRepository(t), alerts)
+
+	w := postAlert(h, "xt-brake", `{"type": "price_drop", "drop_percent": 10, "webhook_url": "https://hooks.example.com/alerts"}`)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("status %d: %s", w.Code, w.Body)
+	}
+	rule := decode[models.AlertRule](t, w)
+	if rule.BaselinePrice != 129.99 || rule.DropPercent != 10 {
+		t.Errorf("got %+v, want a 10%% drop from 129.99", rule)
+	}
+	if len(rule.Secret) != 64 {
+		t.Errorf("generated secret %q, want 32 hex encoded bytes", rule.Secret)
+	}
+
+	// Parts in stock only fire after going out of stock
+	w = postAlert(h, "xt-brake", `{"type": "back_in_stock", "webhook_url": "https://hooks.example.com/alerts", "secret": "s3cret"}`)
+	if rule := decode[models.AlertRule](t, w); !rule.Triggered || rule.Secret != "s3cret" {
+		t.Errorf("got %+v, want a triggered rule with the given secret", rule)
+	}
+
+	// Webhooks and secrets are only returned when rules are created
+	w = serve(h.GetAlerts, http.MethodGet, "/api/v1/parts/xt-brake/alerts", map[string]string{"id": "xt-brake"})
+	if body := w.Body.String(); strings.Contains(body, "webhook_url") || strings.Contains(body, "hooks.example.com") ||
+		strings.Contains(body, "secret") || strings.Contains(body, "s3cret") {
+		t.Errorf("listing %s contains a webhook or secret", body)
+	}
+	if rules := decode[[]models.AlertRule](t, w); len(rules) != 2 {
+		t.Fatalf("got %d rules, want 2", len(rules))
+	}
+}
+
+func TestCreateAlertInvalid(t *testing.T) {
+	h := NewAlertHandler(newTestRepository(t), &fakeAlertRepository{})
+
+	tests := []struct {
+		partID string
+		body   string
+		code   int
+	}{
+		{"missing", `{"type": "price_below", "threshold": 100, "webhook_url": "https://hooks.example.com"}`, http.StatusNotFound},
+		{"xt-brake", `{"type": "price_below"`, http.StatusBadRequest},
+		{"xt-brake", `{"type": "price_below", "threshold": 0, "webhook_url": "https://hooks.example.com"}`, http.StatusBadRequest},
+		{"xt-brake", `{"type": "price_drop", "drop_percent": 100, "webhook_url": "https://hooks.example.com"}`, http.StatusBadRequest},
+		{"xt-brake", `{"type": "price_rise", "webhook_url": "https://hooks.example.com"}`, http.StatusBadRequest},
+		{"xt-brake", `{"type": "back_in_stock", "webhook_url": "ftp://hooks.example.com"}`, http.StatusBadRequest},
+		{"xt-brake", `{"type": "back_in_stock", "webhook_url": "/alerts"}`, http.StatusBadRequest},
+		{"xt-brake", `{"type": "back_in_stock", "webhook_url": "http://169.254.169.254/latest/meta-data/"}`, http.StatusBadRequest},
+		{"xt-brake", `{"type": "back_in_stock", "webhook_url": "http://192.168.0.10/hook"}`, http.StatusBadRequest},
+		{"xt-brake", `{"type": "back_in_stock", "webhook_url": "http://localhost:8080/hook"}`, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		if w := postAlert(h, tt.partID, tt.body); w.Code != tt.code {
+			t.Errorf("%s %s: status %d, want %d", tt.partID, tt.body, w.Code, tt.code)
+		}
+	}
+}
+
+func TestDeleteAlert(t *testing.T) {
+	alerts := &fakeAlertRepository{rules: []models.AlertRule{{ID: "rule-1", PartID: "xt-brake", Secret: "s3cret"}}}
+	h := NewAlertHandler(newTestRepository(t), alerts)
+
+	// Only the creator of a rule, who knows its secret, may delete it
+	tests := []struct {
+		secret string
+		code   int
+	}{
+		{"", http.StatusUnauthorized},
+		{"guess", http.StatusForbidden},
+		{"s3cret", http.StatusNoContent},
+		{"s3cret", http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		w := serveAlert(h.DeleteAlert, http.MethodDelete, "/api/v1/alerts/rule-1", "rule-1", tt.secret)
+		if w.Code != tt.code {
+			t.Errorf("secret %q: status %d, want %d", tt.secret, w.Code, tt.code)
+		}
+	}
+}
+
+func TestGetAlertDeliveries(t *testing.T) {
+	alerts := &fakeAlertRepository{rules: []models.AlertRule{
+		{ID: "rule-1", PartID: "xt-brake", Secret: "s3cret"},
+		{ID: "rule-2", PartID: "xt-brake", Secret: "other"},
+	}, deliveries: []models.AlertDelivery{
+		{ID: 2, AlertID: "rule-1", Status: models.DeliveryDelivered, Payload: []byte(`{}`)},
+		{ID: 1, AlertID: "rule-1", Status: models.DeliveryFailed, Payload: []byte(`{}`)},
+	}}
+	h := NewAlertHandler(newTestRepository(t), alerts)
+
+	w := serveAlert(h.GetAlertDeliveries, http.MethodGet, "/api/v1/alerts/rule-1/deliveries?limit=1", "rule-1", "s3cret")
+	if deliveries := decode[[]models.AlertDelivery](t, w); len(deliveries) != 1 || deliveries[0].ID != 2 {
+		t.Errorf("got %+v, want the latest delivery", deliveries)
+	}
+
+	// Rules without deliveries have an empty log rather than null
+	w = serveAlert(h.GetAlertDeliveries, http.MethodGet, "/api/v1/alerts/rule-2/deliveries", "rule-2", "other")
+	if body := strings.TrimSpace(w.Body.String()); body != "[]" {
+		t.Errorf("got %s, want []", body)
+	}
+
+	w = serveAlert(h.GetAlertDeliveries, http.MethodGet, "/api/v1/alerts/rule-1/deliveries?limit=0", "rule-1", "s3cret")
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("limit=0: status %d, want 400", w.Code)
+	}
+
+	// The log of a rule is only shown with its secret
+	w = serveAlert(h.GetAlertDeliveries, http.MethodGet, "/api/v1/alerts/rule-1/deliveries", "rule-1", "other")
+	if w.Code != http.StatusForbidden {
+		t.Errorf("secret of another rule: status %d, want 403", w.Code)
+	}
+}
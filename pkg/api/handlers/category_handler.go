@@ -0,0 +1,82 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/sosadtsia/bike-parts-finder/pkg/database"
+	"github.com/sosadtsia/bike-parts-finder/pkg/models"
+	"github.com/sosadtsia/bike-parts-finder/pkg/taxonomy"
+)
+
+// CategoryHandler handles category-related API requests
+type CategoryHandler struct {
+	db database.CategoryRepository
+}
+
+// NewCategoryHandler creates a new category handler
+func NewCategoryHandler(db database.CategoryRepository) *CategoryHandler {
+	return &CategoryHandler{
+		db: db,
+	}
+}
+
+// GetCategories returns the category taxonomy as a tree
+func (h *CategoryHandler) GetCategories(w http.ResponseWriter, r *http.Request) {
+	// Set headers
+	w.Header().Set("Content-Type", "application/json")
+
+	categories, err := h.db.GetCategories(r.Context())
+	if err != nil {
+		http.Error(w, "Error fetching categories", http.StatusInternalServerError)
+		return
+	}
+
+	// Return the tree as JSON
+	tree := taxonomy.Tree(categories)
+	if tree == nil {
+		tree = []models.Category{}
+	}
+	if err := json.NewEncoder(w).Encode(tree); err != nil {
+		http.Error(w, "Error encoding response", http.StatusInternalServerError)
+		return
+	}
+}
+
+// GetUnmappedCategories returns the retailer breadcrumbs that no mapping
+// rule matches, leaving out those covered by rules added since they were
+// recorded
+func (h *CategoryHandler) GetUnmappedCategories(w http.ResponseWriter, r *http.Request) {
+	// Set headers
+	w.Header().Set("Content-Type", "application/json")
+
+	categories, err := h.db.GetCategories(r.Context())
+	if err != nil {
+		http.Error(w, "Error fetching categories", http.StatusInternalServerError)
+		return
+	}
+	mappings, err := h.db.GetCategoryMappings(r.Context())
+	if err != nil {
+		http.Error(w, "Error fetching category mappings", http.StatusInternalServerError)
+		return
+	}
+	unmapped, err := h.db.GetUnmappedCategories(r.Context())
+	if err != nil {
+		http.Error(w, "Error fetching unmapped categories", http.StatusInternalServerError)
+		return
+	}
+
+	mapper := taxonomy.NewMapper(categories, mappings)
+	report := []models.UnmappedCategory{}
+	for _, u := range unmapped {
+		if !mapper.Mapped(u.Source, u.Breadcrumbs) {
+			report = append(report, u)
+		}
+	}
+
+	// Return the report as JSON
+	if err := json.NewEncoder(w).Encode(report); err != nil {
+		http.Error(w, "Error encoding response", http.StatusInternalServerError)
+		return
+	}
+}
@@ -0,0 +1,74 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/sosadtsia/bike-parts-finder/pkg/models"
+)
+
+// fakeCategoryRepository returns fixed categories, mappings and unmapped
+// categories
+type fakeCategoryRepository struct {
+	categories []models.Category
+	mappings   []models.CategoryMapping
+	unmapped   []models.UnmappedCategory
+}
+
+func (f *fakeCategoryRepository) GetCategories(ctx context.Context) ([]models.Category, error) {
+	return f.categories, nil
+}
+
+func (f *fakeCategoryRepository) GetCategoryMappings(ctx context.Context) ([]models.CategoryMapping, error) {
+	return f.mappings, nil
+}
+
+func (f *fakeCategoryRepository) GetUnmappedCategories(ctx context.Context) ([]models.UnmappedCategory, error) {
+	return f.unmapped, nil
+}
+
+// testCategories is a small taxonomy
+var testCategories = []models.Category{
+	{ID: "drivetrain", Name: "Drivetrain", PartCount: 1},
+	{ID: "drivetrain/cassettes", Name: "Cassettes", ParentID: "drivetrain", PartCount: 4},
+	{ID: "drivetrain/chains", Name: "Chains", ParentID: "drivetrain", PartCount: 2},
+	{ID: "brakes", Name: "Brakes", Position: -1},
+}
+
+func TestGetCategories(t *testing.T) {
+	h := NewCategoryHandler(&fakeCategoryRepository{categories: testCategories})
+
+	w := serve(h.GetCategories, http.MethodGet, "/api/v1/categories", nil)
+	tree := decode[[]models.Category](t, w)
+	if len(tree) != 2 || tree[0].ID != "brakes" || tree[1].ID != "drivetrain" {
+		t.Fatalf("got %+v, want brakes and drivetrain at the top", tree)
+	}
+	if drivetrain := tree[1]; len(drivetrain.Children) != 2 || drivetrain.PartCount != 7 {
+		t.Errorf("got %+v, want two children and 7 parts", drivetrain)
+	}
+
+	// An empty taxonomy is an empty list rather than null
+	h = NewCategoryHandler(&fakeCategoryRepository{})
+	w = serve(h.GetCategories, http.MethodGet, "/api/v1/categories", nil)
+	if body := w.Body.String(); body != "[]\n" {
+		t.Errorf("got %q, want []", body)
+	}
+}
+
+func TestGetUnmappedCategories(t *testing.T) {
+	h := NewCategoryHandler(&fakeCategoryRepository{
+		categories: testCategories,
+		mappings:   []models.CategoryMapping{{Source: "*", Breadcrumbs: []string{"Cassettes"}, CategoryID: "drivetrain/cassettes"}},
+		unmapped: []models.UnmappedCategory{
+			{Source: "JensonUSA", Breadcrumbs: []string{"Components", "Cassettes"}},
+			{Source: "JensonUSA", Breadcrumbs: []string{"Components", "Pedals"}},
+		},
+	})
+
+	w := serve(h.GetUnmappedCategories, http.MethodGet, "/api/v1/categories/unmapped", nil)
+	report := decode[[]models.UnmappedCategory](t, w)
+	if len(report) != 1 || report[0].Breadcrumbs[1] != "Pedals" {
+		t.Errorf("got %+v, want only the pedals breadcrumbs", report)
+	}
+}
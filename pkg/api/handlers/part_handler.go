@@ -1,39 +1,67 @@
 package handlers
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
+	"strconv"
+	"time"
 
 	"github.com/gorilla/mux"
 	"github.com/sosadtsia/bike-parts-finder/pkg/cache"
 	"github.com/sosadtsia/bike-parts-finder/pkg/database"
+	"github.com/sosadtsia/bike-parts-finder/pkg/models"
+	"github.com/sosadtsia/bike-parts-finder/pkg/pricing"
 )
 
 // PartHandler handles part-related API requests
 type PartHandler struct {
-	db    *database.PostgresClient
-	cache *cache.RedisClient
+	db    database.PartRepository
+	cache cache.PartCache
 }
 
-// NewPartHandler creates a new part handler
-func NewPartHandler(db *database.PostgresClient, cache *cache.RedisClient) *PartHandler {
+// NewPartHandler creates a new part handler. The cache may be nil.
+func NewPartHandler(db database.PartRepository, cache cache.PartCache) *PartHandler {
 	return &PartHandler{
 		db:    db,
 		cache: cache,
 	}
 }
 
-// GetAllParts returns all parts
+// GetAllParts returns all parts, or the parts with a given GTIN when the
+// gtin query parameter is set
 func (h *PartHandler) GetAllParts(w http.ResponseWriter, r *http.Request) {
 	// Set headers
 	w.Header().Set("Content-Type", "application/json")
 
 	// Get query parameters
-	limit := 20
-	offset := 0
+	offset, limit, err := parsePage(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	summary, err := parseBool(r.URL.Query(), "summary")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
 
 	// Get parts from database
-	parts, err := h.db.GetParts(r.Context(), offset, limit)
+	var parts []models.Part
+	if gtinParam := r.URL.Query().Get("gtin"); gtinParam != "" {
+		gtin, ok := models.NormalizeGTIN(gtinParam)
+		if !ok {
+			http.Error(w, "Invalid GTIN", http.StatusBadRequest)
+			return
+		}
+		parts, err = h.db.GetPartsByGTIN(r.Context(), gtin)
+	} else {
+		var result database.SearchResult
+		result, err = h.db.SearchParts(r.Context(), database.SearchOptions{Offset: offset, Limit: limit, Summary: summary != nil && *summary})
+		parts = result.Parts
+	}
 	if err != nil {
 		http.Error(w, "Error fetching parts", http.StatusInternalServerError)
 		return
@@ -56,25 +84,30 @@ func (h *PartHandler) GetPartByID(w http.ResponseWriter, r *http.Request) {
 	id := vars["id"]
 
 	// Try to get part from cache first
-	part, err := h.cache.GetCachedPart(r.Context(), id)
-	if err == nil {
-		// Return cached part as JSON
-		if err := json.NewEncoder(w).Encode(part); err != nil {
-			http.Error(w, "Error encoding response", http.StatusInternalServerError)
+	if h.cache != nil {
+		if part, err := h.cache.GetCachedPart(r.Context(), id); err == nil {
+			// Return cached part as JSON
+			if err := json.NewEncoder(w).Encode(part); err != nil {
+				http.Error(w, "Error encoding response", http.StatusInternalServerError)
+			}
+			return
 		}
-		return
 	}
 
 	// Get part from database
-	part, err = h.db.GetPartByID(r.Context(), id)
-	if err != nil {
+	part, err := h.db.GetPartByID(r.Context(), id)
+	if errors.Is(err, database.ErrPartNotFound) {
 		http.Error(w, "Part not found", http.StatusNotFound)
 		return
 	}
+	if err != nil {
+		http.Error(w, "Error fetching part", http.StatusInternalServerError)
+		return
+	}
 
 	// Cache the part for future requests
 	if h.cache != nil {
-		go h.cache.CachePart(r.Context(), part)
+		go h.cache.CachePart(context.WithoutCancel(r.Context()), part)
 	}
 
 	// Return part as JSON
@@ -84,29 +117,144 @@ func (h *PartHandler) GetPartByID(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-// SearchParts searches for parts
-func (h *PartHandler) SearchParts(w http.ResponseWriter, r *http.Request) {
+// Price history windows in days
+const (
+	defaultPriceHistoryDays = 90
+	maxPriceHistoryDays     = 730
+)
+
+// GetPriceHistory returns the price time series of a part over the last
+// days (default 90) with its lowest, highest and average price
+func (h *PartHandler) GetPriceHistory(w http.ResponseWriter, r *http.Request) {
 	// Set headers
 	w.Header().Set("Content-Type", "application/json")
 
-	// Get query parameters
-	query := r.URL.Query()
-	q := query.Get("q")
-	brand := query.Get("brand")
-	category := query.Get("category")
-	limit := 20
-	offset := 0
+	// Get path parameters
+	vars := mux.Vars(r)
+	id := vars["id"]
 
-	// Search for parts in database
-	parts, err := h.db.SearchParts(r.Context(), q, brand, category, offset, limit)
+	days := defaultPriceHistoryDays
+	if text := r.URL.Query().Get("days"); text != "" {
+		n, err := strconv.Atoi(text)
+		if err != nil || n < 1 || n > maxPriceHistoryDays {
+			http.Error(w, fmt.Sprintf("Invalid days: must be between 1 and %d", maxPriceHistoryDays), http.StatusBadRequest)
+			return
+		}
+		days = n
+	}
+
+	to := time.Now().UTC()
+	from := to.AddDate(0, 0, -days)
+	observations, err := h.db.GetPriceObservations(r.Context(), id, from)
 	if err != nil {
-		http.Error(w, "Error searching parts", http.StatusInternalServerError)
+		http.Error(w, "Error fetching price history", http.StatusInternalServerError)
+		return
+	}
+
+	// Parts without observations may not exist at all
+	if len(observations) == 0 {
+		if _, err := h.db.GetPartByID(r.Context(), id); errors.Is(err, database.ErrPartNotFound) {
+			http.Error(w, "Part not found", http.StatusNotFound)
+			return
+		} else if err != nil {
+			http.Error(w, "Error fetching part", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	// Return the history as JSON
+	if err := json.NewEncoder(w).Encode(pricing.History(id, observations, from, to)); err != nil {
+		http.Error(w, "Error encoding response", http.StatusInternalServerError)
+		return
+	}
+}
+
+// searchResponse is the body of a search response. Fuzzy is set when too
+// few parts matched the query and similar parts were added; DidYouMean is
+// the query with misspelled brand and model words corrected. Facets count
+// all matching parts, not only those of the page, when asked for.
+type searchResponse struct {
+	Parts      []models.Part  `json:"parts"`
+	Fuzzy      bool           `json:"fuzzy,omitempty"`
+	DidYouMean string         `json:"did_you_mean,omitempty"`
+	Facets     []models.Facet `json:"facets,omitempty"`
+}
+
+// SearchParts searches for parts. Parts can be filtered by price, stock,
+// retailer, sub-category, discount, rating and spec values, and sorted by
+// relevance, freshness, price, discount, rating or a spec, and counted by
+// facets; see parseSearchOptions. Invalid and unknown parameters are
+// rejected with 400 Bad Request.
+func (h *PartHandler) SearchParts(w http.ResponseWriter, r *http.Request) {
+	// Set headers
+	w.Header().Set("Content-Type", "application/json")
+
+	result, ok := h.search(w, r)
+	if !ok {
 		return
 	}
 
 	// Return parts as JSON
+	response := searchResponse{
+		Parts:      result.Parts,
+		Fuzzy:      result.Fuzzy,
+		DidYouMean: result.Suggestion,
+		Facets:     result.Facets,
+	}
+	if response.Parts == nil {
+		response.Parts = []models.Part{}
+	}
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		http.Error(w, "Error encoding response", http.StatusInternalServerError)
+		return
+	}
+}
+
+// SearchPartsList searches for parts like SearchParts but returns only the
+// list of parts, the response of the unversioned /api/parts/search route
+// that predates the search envelope
+func (h *PartHandler) SearchPartsList(w http.ResponseWriter, r *http.Request) {
+	// Set headers
+	w.Header().Set("Content-Type", "application/json")
+
+	result, ok := h.search(w, r)
+	if !ok {
+		return
+	}
+
+	// Return parts as JSON
+	parts := result.Parts
+	if parts == nil {
+		parts = []models.Part{}
+	}
 	if err := json.NewEncoder(w).Encode(parts); err != nil {
 		http.Error(w, "Error encoding response", http.StatusInternalServerError)
 		return
 	}
 }
+
+// search runs the search described by a request's query parameters,
+// writing an error response and returning false if it fails
+func (h *PartHandler) search(w http.ResponseWriter, r *http.Request) (database.SearchResult, bool) {
+	// Get query parameters
+	opts, err := parseSearchOptions(r.URL.Query(), func(key string) (bool, error) {
+		return h.db.HasSpecKey(r.Context(), key)
+	})
+	var paramErr *paramError
+	if errors.As(err, &paramErr) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return database.SearchResult{}, false
+	}
+	if err != nil {
+		http.Error(w, "Error searching parts", http.StatusInternalServerError)
+		return database.SearchResult{}, false
+	}
+
+	// Search for parts in database
+	result, err := h.db.SearchParts(r.Context(), opts)
+	if err != nil {
+		http.Error(w, "Error searching parts", http.StatusInternalServerError)
+		return database.SearchResult{}, false
+	}
+	return result, true
+}
@@ -0,0 +1,341 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/sosadtsia/bike-parts-finder/pkg/cache"
+	"github.com/sosadtsia/bike-parts-finder/pkg/database"
+	"github.com/sosadtsia/bike-parts-finder/pkg/models"
+)
+
+// testParts are stored by newTestRepository, oldest first
+var testParts = []models.Part{
+	{
+		ID: "xt-brake", Brand: "Shimano", Model: "XT M8120 Brake", Category: "Brakes", SubCategory: "Disc Brakes",
+		Price: 129.99, MSRP: 149.99, Currency: "USD", InStock: true, Source: "JensonUSA", Rating: 4.5,
+		GTIN: "04006381333931", Description: "Four piston hydraulic disc brake",
+		Images: []string{"https://example.com/xt.jpg"},
+		Specs:  []models.Spec{{Name: "Speed", Value: "12", Key: "speeds", NumericValue: ptr(12.0)}},
+	},
+	{
+		ID: "slx-cassette", Brand: "Shimano", Model: "SLX M7100 Cassette", Category: "Drivetrain", SubCategory: "Cassettes",
+		Price: 89.99, Currency: "USD", InStock: false, Source: "Competitive Cyclist", Rating: 4.0,
+		GTIN: "04006381333931", Description: "12-speed cassette, 10-51t",
+		Specs: []models.Spec{{Name: "Speed", Value: "12", Key: "speeds", NumericValue: ptr(12.0)}},
+	},
+	{
+		ID: "gx-cassette", Brand: "SRAM", Model: "GX Eagle Cassette", Category: "Drivetrain", SubCategory: "Cassettes",
+		Price: 1100, MSRP: 1200, Currency: "USD", InStock: true, Source: "JensonUSA",
+		Description: "Eagle 12-speed cassette",
+		Specs: []models.Spec{
+			{Name: "Speed", Value: "11", Key: "speeds", NumericValue: ptr(11.0)},
+			{Name: "Material", Value: "Aluminium", Key: "material"},
+		},
+	},
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
+
+// newTestRepository creates a memory repository holding testParts
+func newTestRepository(t *testing.T) *database.MemoryPartRepository {
+	t.Helper()
+	repo := database.NewMemoryPartRepository()
+	created := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i, part := range testParts {
+		part.CreatedAt = created.Add(time.Duration(i) * time.Hour)
+		if err := repo.StorePart(context.Background(), part); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return repo
+}
+
+// serve sends a request to a handler, setting the mux route variables
+func serve(handler http.HandlerFunc, method, target string, vars map[string]string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(method, target, nil)
+	if vars != nil {
+		r = mux.SetURLVars(r, vars)
+	}
+	w := httptest.NewRecorder()
+	handler(w, r)
+	return w
+}
+
+// decode decodes a JSON response body
+func decode[T any](t *testing.T, w *httptest.ResponseRecorder) T {
+	t.Helper()
+	var v T
+	if err := json.Unmarshal(w.Body.Bytes(), &v); err != nil {
+		t.Fatalf("decoding response %q: %v", w.Body, err)
+	}
+	return v
+}
+
+func partIDs(parts []models.Part) []string {
+	ids := make([]string, len(parts))
+	for i, part := range parts {
+		ids[i] = part.ID
+	}
+	return ids
+}
+
+func TestGetAllParts(t *testing.T) {
+	h := NewPartHandler(newTestRepository(t), nil)
+
+	tests := []struct {
+		target string
+		code   int
+		ids    []string
+	}{
+		{"/api/v1/parts", http.StatusOK, []string{"gx-cassette", "slx-cassette", "xt-brake"}},
+		{"/api/v1/parts?limit=2&page=2", http.StatusOK, []string{"xt-brake"}},
+		{"/api/v1/parts?gtin=4006381333931", http.StatusOK, []string{"slx-cassette", "xt-brake"}},
+		{"/api/v1/parts?gtin=123", http.StatusBadRequest, nil},
+		{"/api/v1/parts?page=0", http.StatusBadRequest, nil},
+		{"/api/v1/parts?limit=51", http.StatusBadRequest, nil},
+		{"/api/v1/parts?summary=maybe", http.StatusBadRequest, nil},
+	}
+	for _, tt := range tests {
+		w := serve(h.GetAllParts, http.MethodGet, tt.target, nil)
+		if w.Code != tt.code {
+			t.Errorf("GET %s: status %d, want %d", tt.target, w.Code, tt.code)
+			continue
+		}
+		if tt.code != http.StatusOK {
+			continue
+		}
+		if ids := partIDs(decode[[]models.Part](t, w)); !slices.Equal(ids, tt.ids) {
+			t.Errorf("GET %s: got %v, want %v", tt.target, ids, tt.ids)
+		}
+	}
+}
+
+func TestGetAllPartsSummary(t *testing.T) {
+	h := NewPartHandler(newTestRepository(t), nil)
+
+	w := serve(h.GetAllParts, http.MethodGet, "/api/v1/parts?summary=true", nil)
+	for _, part := range decode[[]models.Part](t, w) {
+		if part.Specs != nil || part.Images != nil {
+			t.Errorf("part %s has specs or images in a summary", part.ID)
+		}
+	}
+}
+
+func TestGetPartByID(t *testing.T) {
+	partCache := cache.NewMemoryCache()
+	h := NewPartHandler(newTestRepository(t), partCache)
+
+	w := serve(h.GetPartByID, http.MethodGet, "/api/v1/parts/xt-brake", map[string]string{"id": "xt-brake"})
+	if w.Code != http.StatusOK {
+		t.Fatalf("status %d, want 200", w.Code)
+	}
+	if part := decode[models.Part](t, w); part.Brand != "Shimano" || len(part.Specs) != 1 {
+		t.Errorf("got %+v", part)
+	}
+
+	// The part is cached in the background
+	deadline := time.Now().Add(time.Second)
+	for {
+		if _, err := partCache.GetCachedPart(context.Background(), "xt-brake"); err == nil {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("part was not cached")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	w = serve(h.GetPartByID, http.MethodGet, "/api/v1/parts/missing", map[string]string{"id": "missing"})
+	if w.Code != http.StatusNotFound {
+		t.Errorf("missing part: status %d, want 404", w.Code)
+	}
+}
+
+func TestGetPartByIDFromCache(t *testing.T) {
+	partCache := cache.NewMemoryCache()
+	cached := models.Part{ID: "cached", Brand: "Hope", Model: "Tech 4"}
+	if err := partCache.CachePart(context.Background(), cached); err != nil {
+		t.Fatal(err)
+	}
+	h := NewPartHandler(database.NewMemoryPartRepository(), partCache)
+
+	w := serve(h.GetPartByID, http.MethodGet, "/api/v1/parts/cached", map[string]string{"id": "cached"})
+	if w.Code != http.StatusOK {
+		t.Fatalf("status %d, want 200", w.Code)
+	}
+	if part := decode[models.Part](t, w); part.Brand != "Hope" {
+		t.Errorf("got %+v, want the cached part", part)
+	}
+}
+
+func TestGetPriceHistory(t *testing.T) {
+	repo := newTestRepository(t)
+	part := testParts[0]
+	if _, err := repo.RecordPriceObservation(context.Background(), part); err != nil {
+		t.Fatal(err)
+	}
+	h := NewPartHandler(repo, nil)
+
+	w := serve(h.GetPriceHistory, http.MethodGet, "/api/v1/parts/xt-brake/price-history", map[string]string{"id": "xt-brake"})
+	if w.Code != http.StatusOK {
+		t.Fatalf("status %d, want 200", w.Code)
+	}
+	history := decode[models.PriceHistory](t, w)
+	if len(history.Observations) != 1 || history.Current != part.Price || history.Min != part.Price {
+		t.Errorf("got %+v", history)
+	}
+
+	// Parts without observations have an empty history
+	w = serve(h.GetPriceHistory, http.MethodGet, "/api/v1/parts/gx-cassette/price-history", map[string]string{"id": "gx-cassette"})
+	if w.Code != http.StatusOK {
+		t.Errorf("part without observations: status %d, want 200", w.Code)
+	}
+
+	w = serve(h.GetPriceHistory, http.MethodGet, "/api/v1/parts/missing/price-history", map[string]string{"id": "missing"})
+	if w.Code != http.StatusNotFound {
+		t.Errorf("missing part: status %d, want 404", w.Code)
+	}
+
+	w = serve(h.GetPriceHistory, http.MethodGet, "/api/v1/parts/xt-brake/price-history?days=731", map[string]string{"id": "xt-brake"})
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("days=731: status %d, want 400", w.Code)
+	}
+}
+
+func TestSearchParts(t *testing.T) {
+	h := NewPartHandler(newTestRepository(t), nil)
+
+	tests := []struct {
+		query string
+		ids   []string
+	}{
+		{"q=shimano", []string{"slx-cassette", "xt-brake"}},
+		{"q=shimano+cass", []string{"slx-cassette"}},
+		{"q=cassette&sort=price", []string{"slx-cassette", "gx-cassette"}},
+		{"q=cassette&sort=-price", []string{"gx-cassette", "slx-cassette"}},
+		{"q=cassette&sort=speeds", []string{"gx-cassette", "slx-cassette"}},
+		{"brand=shimano&category=drivetrain", []string{"slx-cassette"}},
+		{"brand=shimano&in_stock=true", []string{"xt-brake"}},
+		{"source=jensonusa&price_max=200", []string{"xt-brake"}},
+		{"sub_category=cassettes&speeds=11", []string{"gx-cassette"}},
+		{"speeds_min=12&sort=freshness", []string{"slx-cassette", "xt-brake"}},
+		{"rating_min=4.2", []string{"xt-brake"}},
+		{"material=ALUMINIUM", []string{"gx-cassette"}},
+		{"sort=discount", []string{"xt-brake", "gx-cassette", "slx-cassette"}},
+		{"sort=rating", []string{"xt-brake", "slx-cassette", "gx-cassette"}},
+		{"q=nothing+like+this", []string{}},
+	}
+	for _, tt := range tests {
+		w := serve(h.SearchParts, http.MethodGet, "/api/v1/parts/search?"+tt.query, nil)
+		if w.Code != http.StatusOK {
+			t.Errorf("%s: status %d: %s", tt.query, w.Code, w.Body)
+			continue
+		}
+		response := decode[searchResponse](t, w)
+		if ids := partIDs(response.Parts); !slices.Equal(ids, tt.ids) {
+			t.Errorf("%s: got %v, want %v", tt.query, ids, tt.ids)
+		}
+	}
+}
+
+// stubSearchRepository returns a fixed search result and records the
+// options of the last search
+type stubSearchRepository struct {
+	*database.MemoryPartRepository
+	result database.SearchResult
+	opts   database.SearchOptions
+}
+
+func (s *stubSearchRepository) SearchParts(ctx context.Context, opts database.SearchOptions) (database.SearchResult, error) {
+	s.opts = opts
+	return s.result, nil
+}
+
+func TestSearchPartsResult(t *testing.T) {
+	repo := &stubSearchRepository{
+		MemoryPartRepository: newTestRepository(t),
+		result: database.SearchResult{
+			Parts:      []models.Part{{ID: "slx-cassette", Snippet: "12-speed <mark>cassette</mark>, 10-51t"}},
+			Fuzzy:      true,
+			Suggestion: "shimano cassette",
+			Facets: []models.Facet{
+				{Name: "brand", Values: []models.FacetValue{{Value: "Shimano", Count: 2}}},
+				{Name: "speeds", Values: []models.FacetValue{{Value: "12", Count: 2}}},
+			},
+		},
+	}
+	h := NewPartHandler(repo, nil)
+
+	w := serve(h.SearchParts, http.MethodGet, "/api/v1/parts/search?q=shimno+casette&facets=brand,speed&limit=1", nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", w.Code, w.Body)
+	}
+	// Facets are resolved to canonical spec keys
+	if opts := repo.opts; opts.Query != "shimno casette" || opts.Limit != 1 || !slices.Equal(opts.Facets, []string{"brand", "speeds"}) {
+		t.Errorf("searched with %+v", opts)
+	}
+
+	response := decode[searchResponse](t, w)
+	if !response.Fuzzy || response.DidYouMean != "shimano cassette" {
+		t.Errorf("fuzzy %v, did_you_mean %q; want a fuzzy search suggesting shimano cassette", response.Fuzzy, response.DidYouMean)
+	}
+	if len(response.Parts) != 1 || response.Parts[0].Snippet != repo.result.Parts[0].Snippet {
+		t.Errorf("got %+v, want the part with its snippet", response.Parts)
+	}
+	if len(response.Facets) != 2 || response.Facets[1].Name != "speeds" || response.Facets[1].Values[0].Count != 2 {
+		t.Errorf("got facets %+v", response.Facets)
+	}
+
+	// Searches without fuzzy matches or facets leave them out
+	repo.result = database.SearchResult{}
+	w = serve(h.SearchParts, http.MethodGet, "/api/v1/parts/search?q=shimano", nil)
+	if body := strings.TrimSpace(w.Body.String()); body != `{"parts":[]}` {
+		t.Errorf("got %s, want only an empty list of parts", body)
+	}
+}
+
+func TestSearchPartsInvalid(t *testing.T) {
+	h := NewPartHandler(newTestRepository(t), nil)
+
+	tests := []struct {
+		query string
+		err   string
+	}{
+		{"price_min=10&price_max=5", "Invalid price_min: must not be greater than price_max"},
+		{"rating_min=6", "Invalid rating_min: must be from 0 to 5"},
+		{"in_stock=maybe", "Invalid in_stock: must be true or false"},
+		{"price=10", "Invalid price: use price_min or price_max"},
+		{"sort=-relevance", "Invalid sort: relevance cannot be reversed"},
+		{"q=shimano&sort=-discount", "Invalid sort: discount cannot be reversed"},
+		{"sort=-rating", "Invalid sort: rating cannot be reversed"},
+		{"sort=relevance", "Invalid sort: relevance needs a query in q"},
+		{"q=+&sort=relevance", "Invalid sort: relevance needs a query in q"},
+		{"sort=colour", `Invalid sort: unknown order or attribute "colour"`},
+		{"speeds=fast", "Invalid speeds: must be a number in speeds"},
+		{"speeds_min=fast", "Invalid speeds_min: must be a number in speeds"},
+		{"facets=brand,,price", "Invalid facets: must be a comma separated list of names"},
+		{"brnad=Shimano", "Invalid brnad: unknown parameter"},
+		{"instock=true", "Invalid instock: unknown parameter"},
+		{"facets=brand,colour", `Invalid facets: unknown facet "colour"`},
+	}
+	for _, tt := range tests {
+		w := serve(h.SearchParts, http.MethodGet, "/api/v1/parts/search?"+tt.query, nil)
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("%s: status %d, want 400", tt.query, w.Code)
+			continue
+		}
+		if got := w.Body.String(); got != tt.err+"\n" {
+			t.Errorf("%s: got %q, want %q", tt.query, got, tt.err)
+		}
+	}
+}
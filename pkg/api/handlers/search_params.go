@@ -0,0 +1,340 @@
+package handlers
+
+import (
+	"fmt"
+	"math"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/sosadtsia/bike-parts-finder/pkg/database"
+	"github.com/sosadtsia/bike-parts-finder/pkg/specs"
+)
+
+// Pagination of part lists
+const (
+	defaultPageSize = 20
+	maxPageSize     = 50
+	maxPage         = 10000
+)
+
+// maxFacets is the most facets a search counts
+const maxFacets = 10
+
+// fieldFacets are the facets of part fields; other facets are spec
+// attributes
+var fieldFacets = map[string]bool{
+	database.FacetBrand: true, database.FacetCategory: true, database.FacetSubCategory: true,
+	database.FacetSource: true, database.FacetInStock: true, database.FacetPrice: true,
+}
+
+// searchParams are the search query parameters other than spec filters
+var searchParams = map[string]bool{
+	"q": true, "brand": true, "category": true, "sub_category": true, "source": true, "in_stock": true,
+	"price_min": true, "price_max": true, "discount_min": true, "rating_min": true,
+	"sort": true, "page": true, "limit": true, "summary": true, "facets": true,
+}
+
+// rangeParams are part fields that are only filtered by bounds, with the
+// parameters to use instead
+var rangeParams = map[string]string{
+	"price":    "price_min or price_max",
+	"discount": "discount_min",
+	"rating":   "rating_min",
+}
+
+// paramError is an invalid query parameter, reported to the client
+type paramError struct {
+	name    string
+	problem string
+}
+
+func (e *paramError) Error() string {
+	return fmt.Sprintf("Invalid %s: %s", e.name, e.problem)
+}
+
+// parseSearchOptions reads the filters, order and page of a search from
+// query parameters. Any parameter that is not a search parameter filters by
+// a spec: <attribute>=<value> requires that value, e.g. speed=12 or
+// wheel_size=29, and <attribute>_min and <attribute>_max bound numeric
+// attributes. facets is a comma separated list of fields and spec
+// attributes to count the results by. Specs other than the known
+// attributes are only accepted if hasSpecKey finds parts with them, so that
+// misspelled parameters are rejected rather than matching nothing.
+func parseSearchOptions(query url.Values, hasSpecKey func(key string) (bool, error)) (database.SearchOptions, error) {
+	opts := database.SearchOptions{
+		Query:       query.Get("q"),
+		Brand:       query.Get("brand"),
+		Category:    query.Get("category"),
+		SubCategory: query.Get("sub_category"),
+		Source:      query.Get("source"),
+	}
+
+	var err error
+	if opts.Offset, opts.Limit, err = parsePage(query); err != nil {
+		return opts, err
+	}
+	if opts.InStock, err = parseBool(query, "in_stock"); err != nil {
+		return opts, err
+	}
+	if summary, err := parseBool(query, "summary"); err != nil {
+		return opts, err
+	} else if summary != nil {
+		opts.Summary = *summary
+	}
+
+	if opts.MinPrice, err = parseNumber(query, "price_min", 0, math.Inf(1)); err != nil {
+		return opts, err
+	}
+	if opts.MaxPrice, err = parseNumber(query, "price_max", 0, math.Inf(1)); err != nil {
+		return opts, err
+	}
+	if opts.MinPrice != nil && opts.MaxPrice != nil && *opts.MinPrice > *opts.MaxPrice {
+		return opts, &paramError{"price_min", "must not be greater than price_max"}
+	}
+	if opts.MinDiscount, err = parseNumber(query, "discount_min", 0, 100); err != nil {
+		return opts, err
+	}
+	if opts.MinRating, err = parseNumber(query, "rating_min", 0, 5); err != nil {
+		return opts, err
+	}
+
+	if opts.Specs, err = parseSpecFilters(query, hasSpecKey); err != nil {
+		return opts, err
+	}
+
+	if err := parseSort(query.Get("sort"), &opts); err != nil {
+		return opts, err
+	}
+
+	if opts.Facets, err = parseFacets(query.Get("facets"), hasSpecKey); err != nil {
+		return opts, err
+	}
+
+	return opts, nil
+}
+
+// parseSpecFilters reads the spec filters of a search, in the order of
+// their parameter names
+func parseSpecFilters(query url.Values, hasSpecKey func(key string) (bool, error)) ([]database.SpecFilter, error) {
+	var names []string
+	for name := range query {
+		if !searchParams[name] {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	var filters []database.SpecFilter
+	bounds := make(map[string]int)
+	for _, name := range names {
+		if hint, ok := rangeParams[name]; ok {
+			return nil, &paramError{name, "use " + hint}
+		}
+
+		// Bounds on a numeric attribute
+		attrName, suffix := name, ""
+		for _, s := range []string{"_min", "_max"} {
+			if strings.HasSuffix(name, s) {
+				attrName, suffix = strings.TrimSuffix(name, s), s
+			}
+		}
+		if suffix != "" {
+			attr, ok := specs.Resolve(attrName)
+			if !ok {
+				return nil, &paramError{name, fmt.Sprintf("%q is not a numeric spec attribute", attrName)}
+			}
+			value, err := parseNumber(query, name, math.Inf(-1), math.Inf(1))
+			if err != nil {
+				return nil, &paramError{name, "must be a number in " + attr.Unit}
+			}
+			if value == nil {
+				continue
+			}
+
+			i, ok := bounds[attr.Key]
+			if !ok {
+				i = len(filters)
+				bounds[attr.Key] = i
+				filters = append(filters, database.SpecFilter{Key: attr.Key})
+			}
+			if suffix == "_min" {
+				filters[i].Min = value
+			} else {
+				filters[i].Max = value
+			}
+			if filters[i].Min != nil && filters[i].Max != nil && *filters[i].Min > *filters[i].Max {
+				return nil, &paramError{attrName + "_min", "must not be greater than " + attrName + "_max"}
+			}
+			continue
+		}
+
+		// A value of a numeric attribute, in its unit or with one it is
+		// converted from, or the text of any other spec
+		text := query.Get(name)
+		if text == "" {
+			return nil, &paramError{name, "must not be empty"}
+		}
+		if attr, ok := specs.Resolve(name); ok {
+			value, ok := attr.Parse(text)
+			if !ok {
+				return nil, &paramError{name, "must be a number in " + attr.Unit}
+			}
+			filters = append(filters, database.SpecFilter{Key: attr.Key, Min: &value, Max: &value})
+			continue
+		}
+		key, err := specKey(name, hasSpecKey)
+		if err != nil {
+			return nil, err
+		}
+		if key == "" {
+			return nil, &paramError{name, "unknown parameter"}
+		}
+		filters = append(filters, database.SpecFilter{Key: key, Value: text})
+	}
+
+	return filters, nil
+}
+
+// specKey returns the key of a spec attribute or of specs that parts have,
+// or "" for unknown names
+func specKey(name string, hasSpecKey func(key string) (bool, error)) (string, error) {
+	if attr, ok := specs.Resolve(name); ok {
+		return attr.Key, nil
+	}
+	key := specs.KeyFor(name)
+	if key == "" {
+		return "", nil
+	}
+	exists, err := hasSpecKey(key)
+	if err != nil || !exists {
+		return "", err
+	}
+	return key, nil
+}
+
+// parseSort reads the order of a search: relevance, which needs a query,
+// freshness, discount or rating, or price or a numeric spec attribute,
+// prefixed with - for descending order. Only price and spec orders can be
+// reversed.
+func parseSort(text string, opts *database.SearchOptions) error {
+	key := strings.TrimPrefix(text, "-")
+	desc := key != text
+
+	switch key {
+	case "":
+		if desc {
+			return &paramError{"sort", "missing order after -"}
+		}
+	case database.SortRelevance, database.SortFreshness, database.SortDiscount, database.SortRating:
+		if desc {
+			return &paramError{"sort", key + " cannot be reversed"}
+		}
+		if key == database.SortRelevance && strings.TrimSpace(opts.Query) == "" {
+			return &paramError{"sort", "relevance needs a query in q"}
+		}
+		opts.Sort = key
+	case database.SortPrice:
+		opts.Sort, opts.SortDesc = key, desc
+	default:
+		attr, ok := specs.Resolve(key)
+		if !ok {
+			return &paramError{"sort", fmt.Sprintf("unknown order or attribute %q", key)}
+		}
+		opts.SortSpec, opts.SortDesc = attr.Key, desc
+	}
+
+	return nil
+}
+
+// parseFacets reads the facets of a search: brand, category,
+// sub_category, source, in_stock, price or spec attributes such as speed,
+// each counted once
+func parseFacets(text string, hasSpecKey func(key string) (bool, error)) ([]string, error) {
+	if text == "" {
+		return nil, nil
+	}
+
+	var facets []string
+	seen := make(map[string]bool)
+	for _, name := range strings.Split(text, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			return nil, &paramError{"facets", "must be a comma separated list of names"}
+		}
+		if !fieldFacets[name] {
+			key, err := specKey(name, hasSpecKey)
+			if err != nil {
+				return nil, err
+			}
+			if key == "" {
+				return nil, &paramError{"facets", fmt.Sprintf("unknown facet %q", name)}
+			}
+			name = key
+		}
+		if seen[name] {
+			continue
+		}
+		seen[name] = true
+		facets = append(facets, name)
+	}
+	if len(facets) > maxFacets {
+		return nil, &paramError{"facets", fmt.Sprintf("must not have more than %d names", maxFacets)}
+	}
+
+	return facets, nil
+}
+
+// parsePage reads the page and limit query parameters into an offset and
+// limit
+func parsePage(query url.Values) (int, int, error) {
+	page, limit := 1, defaultPageSize
+	if text := query.Get("page"); text != "" {
+		n, err := strconv.Atoi(text)
+		if err != nil || n < 1 || n > maxPage {
+			return 0, 0, &paramError{"page", fmt.Sprintf("must be an integer from 1 to %d", maxPage)}
+		}
+		page = n
+	}
+	if text := query.Get("limit"); text != "" {
+		n, err := strconv.Atoi(text)
+		if err != nil || n < 1 || n > maxPageSize {
+			return 0, 0, &paramError{"limit", fmt.Sprintf("must be an integer from 1 to %d", maxPageSize)}
+		}
+		limit = n
+	}
+	return (page - 1) * limit, limit, nil
+}
+
+// parseBool reads an optional boolean query parameter
+func parseBool(query url.Values, name string) (*bool, error) {
+	text := query.Get(name)
+	if text == "" {
+		return nil, nil
+	}
+	value, err := strconv.ParseBool(text)
+	if err != nil {
+		return nil, &paramError{name, "must be true or false"}
+	}
+	return &value, nil
+}
+
+// parseNumber reads an optional number query parameter from lo to hi
+func parseNumber(query url.Values, name string, lo, hi float64) (*float64, error) {
+	text := query.Get(name)
+	if text == "" {
+		return nil, nil
+	}
+	value, err := strconv.ParseFloat(text, 64)
+	if err != nil || math.IsNaN(value) || math.IsInf(value, 0) {
+		return nil, &paramError{name, "must be a number"}
+	}
+	if value < lo || value > hi {
+		if math.IsInf(hi, 1) {
+			return nil, &paramError{name, fmt.Sprintf("must be at least %g", lo)}
+		}
+		return nil, &paramError{name, fmt.Sprintf("must be from %g to %g", lo, hi)}
+	}
+	return &value, nil
+}
@@ -0,0 +1,15 @@
+package cache
+
+import (
+	"context"
+
+	"github.com/sosadtsia/bike-parts-finder/pkg/models"
+)
+
+// PartCache caches parts by ID
+type PartCache interface {
+	CachePart(ctx context.Context, part models.Part) error
+	GetCachedPart(ctx context.Context, id string) (models.Part, error)
+}
+
+var _ PartCache = (*RedisClient)(nil)
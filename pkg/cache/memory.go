@@ -0,0 +1,73 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/sosadtsia/bike-parts-finder/pkg/models"
+)
+
+// partTTL is how long a cached part is kept
+const partTTL = 24 * time.Hour
+
+// MemoryCache is a PartCache kept in memory, for tests and local
+// development without Redis. Like RedisClient it stores parts as JSON, so
+// cached parts read back as they would from Redis.
+type MemoryCache struct {
+	mu      sync.Mutex
+	entries map[string]memoryEntry
+}
+
+var _ PartCache = (*MemoryCache)(nil)
+
+// memoryEntry is a cached value and when it expires
+type memoryEntry struct {
+	data    []byte
+	expires time.Time
+}
+
+// NewMemoryCache creates an empty in-memory cache
+func NewMemoryCache() *MemoryCache {
+	return &MemoryCache{
+		entries: make(map[string]memoryEntry),
+	}
+}
+
+// CachePart caches a part
+func (c *MemoryCache) CachePart(ctx context.Context, part models.Part) error {
+	data, err := json.Marshal(part)
+	if err != nil {
+		return fmt.Errorf("marshaling part %s: %w", part.ID, err)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries[part.ID] = memoryEntry{data: data, expires: time.Now().Add(partTTL)}
+
+	return nil
+}
+
+// GetCachedPart retrieves a cached part
+func (c *MemoryCache) GetCachedPart(ctx context.Context, id string) (models.Part, error) {
+	var part models.Part
+
+	c.mu.Lock()
+	entry, ok := c.entries[id]
+	if ok && time.Now().After(entry.expires) {
+		delete(c.entries, id)
+		ok = false
+	}
+	c.mu.Unlock()
+
+	if !ok {
+		return part, fmt.Errorf("getting part %s from cache: not found", id)
+	}
+	if err := json.Unmarshal(entry.data, &part); err != nil {
+		return part, fmt.Errorf("unmarshaling part %s: %w", id, err)
+	}
+
+	return part, nil
+}
@@ -67,7 +67,7 @@ func (c *RedisClient) CachePart(ctx context.Context, part models.Part) error {
 		return fmt.Errorf("marshaling part %s: %w", part.ID, err)
 	}
 
-	return c.Set(ctx, key, string(data), 24*time.Hour)
+	return c.Set(ctx, key, string(data), partTTL)
 }
 
 // GetCachedPart retrieves a cached part
@@ -0,0 +1,219 @@
+package database
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/sosadtsia/bike-parts-finder/pkg/models"
+)
+
+// alertRuleColumns are the columns scanned by scanAlertRule
+const alertRuleColumns = `id, part_id, type, COALESCE(threshold, 0), COALESCE(drop_percent, 0),
+	COALESCE(baseline_price, 0), webhook_url, secret, triggered, last_triggered_at, created_at`
+
+// scanAlertRule scans a row selected with alertRuleColumns
+func scanAlertRule(row interface{ Scan(...any) error }) (models.AlertRule, error) {
+	var rule models.AlertRule
+	err := row.Scan(
+		&rule.ID, &rule.PartID, &rule.Type, &rule.Threshold, &rule.DropPercent,
+		&rule.BaselinePrice, &rule.WebhookURL, &rule.Secret, &rule.Triggered, &rule.LastTriggeredAt, &rule.CreatedAt,
+	)
+	return rule, err
+}
+
+// CreateAlertRule stores a new alert rule
+func (c *PostgresClient) CreateAlertRule(ctx context.Context, rule models.AlertRule) (models.AlertRule, error) {
+	row := c.pool.QueryRow(ctx, `
+		INSERT INTO alert_rules (id, part_id, type, threshold, drop_percent, baseline_price, webhook_url, secret, triggered)
+		VALUES ($1, $2, $3, NULLIF($4::numeric, 0), NULLIF($5::numeric, 0), NULLIF($6::numeric, 0), $7, $8, $9)
+		RETURNING `+alertRuleColumns,
+		rule.ID, rule.PartID, rule.Type, rule.Threshold, rule.DropPercent, rule.BaselinePrice,
+		rule.WebhookURL, rule.Secret, rule.Triggered,
+	)
+	return scanAlertRule(row)
+}
+
+// GetAlertRule retrieves an alert rule by ID
+func (c *PostgresClient) GetAlertRule(ctx context.Context, id string) (models.AlertRule, error) {
+	row := c.pool.QueryRow(ctx, "SELECT "+alertRuleColumns+" FROM alert_rules WHERE id = $1", id)
+	rule, err := scanAlertRule(row)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return rule, ErrAlertNotFound
+	}
+	return rule, err
+}
+
+// GetAlertRules retrieves the alert rules of a part, oldest first
+func (c *PostgresClient) GetAlertRules(ctx context.Context, partID string) ([]models.AlertRule, error) {
+	return c.queryAlertRules(ctx, "SELECT "+alertRuleColumns+" FROM alert_rules WHERE part_id = $1 ORDER BY created_at", partID)
+}
+
+// GetAlertRulesForParts retrieves the alert rules of any of the given parts
+func (c *PostgresClient) GetAlertRulesForParts(ctx context.Context, partIDs []string) ([]models.AlertRule, error) {
+	return c.queryAlertRules(ctx, "SELECT "+alertRuleColumns+" FROM alert_rules WHERE part_id = ANY($1)", partIDs)
+}
+
+// queryAlertRules retrieves the alert rules selected by a query
+func (c *PostgresClient) queryAlertRules(ctx context.Context, query string, args ...any) ([]models.AlertRule, error) {
+	rows, err := c.pool.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var rules []models.AlertRule
+	for rows.Next() {
+		rule, err := scanAlertRule(rows)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, rule)
+	}
+
+	return rules, rows.Err()
+}
+
+// DeleteAlertRule deletes an alert rule and its deliveries. It reports
+// whether the rule existed.
+func (c *PostgresClient) DeleteAlertRule(ctx context.Context, id string) (bool, error) {
+	tag, err := c.pool.Exec(ctx, "DELETE FROM alert_rules WHERE id = $1", id)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() > 0, nil
+}
+
+// TriggerAlert marks a rule as triggered and queues the delivery of its
+// event
+func (c *PostgresClient) TriggerAlert(ctx context.Context, event models.AlertEvent) error {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("encoding alert event: %w", err)
+	}
+
+	tx, err := c.pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	_, err = tx.Exec(ctx, `
+		UPDATE alert_rules SET triggered = TRUE, last_triggered_at = $2 WHERE id = $1
+	`, event.AlertID, event.TriggeredAt)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(ctx, `
+		INSERT INTO alert_deliveries (alert_id, payload) VALUES ($1, $2)
+	`, event.AlertID, payload)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+// RearmAlert clears the triggered state of a rule so it fires again the
+// next time its condition is met
+func (c *PostgresClient) RearmAlert(ctx context.Context, id string) error {
+	_, err := c.pool.Exec(ctx, "UPDATE alert_rules SET triggered = FALSE WHERE id = $1", id)
+	return err
+}
+
+// ClaimAlertDeliveries claims up to limit pending deliveries that are due,
+// with the webhooks of their rules. Claimed deliveries are not claimed
+// again for lease, so concurrent consumers do not send them twice.
+func (c *PostgresClient) ClaimAlertDeliveries(ctx context.Context, limit int, lease time.Duration) ([]models.AlertDelivery, error) {
+	rows, err := c.pool.Query(ctx, `
+		WITH due AS (
+			SELECT id FROM alert_deliveries
+			WHERE status = 'pending' AND next_attempt_at <= NOW()
+			ORDER BY next_attempt_at
+			LIMIT $1
+			FOR UPDATE SKIP LOCKED
+		)
+		UPDATE alert_deliveries d
+		SET next_attempt_at = NOW() + $2::float8 * INTERVAL '1 second'
+		FROM due, alert_rules r
+		WHERE d.id = due.id AND r.id = d.alert_id
+		RETURNING d.id, d.alert_id, d.payload, d.attempts, r.webhook_url, r.secret
+	`, limit, lease.Seconds())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var deliveries []models.AlertDelivery
+	for rows.Next() {
+		delivery := models.AlertDelivery{Status: models.DeliveryPending}
+		var payload []byte
+		if err := rows.Scan(
+			&delivery.ID, &delivery.AlertID, &payload, &delivery.Attempts,
+			&delivery.WebhookURL, &delivery.Secret,
+		); err != nil {
+			return nil, err
+		}
+		delivery.Payload = payload
+		deliveries = append(deliveries, delivery)
+	}
+
+	return deliveries, rows.Err()
+}
+
+// RecordAlertDeliveryAttempt records the outcome of an attempt to deliver
+// an alert. Failed attempts are retried at nextAttempt unless status is
+// failed.
+func (c *PostgresClient) RecordAlertDeliveryAttempt(ctx context.Context, id int64, status string, statusCode int, attemptErr error, nextAttempt time.Time) error {
+	var lastError string
+	if attemptErr != nil {
+		lastError = attemptErr.Error()
+	}
+	_, err := c.pool.Exec(ctx, `
+		UPDATE alert_deliveries SET
+			status = $2,
+			attempts = attempts + 1,
+			last_status_code = NULLIF($3::integer, 0),
+			last_error = NULLIF($4, ''),
+			next_attempt_at = $5,
+			delivered_at = CASE WHEN $2 = 'delivered' THEN NOW() END
+		WHERE id = $1
+	`, id, status, statusCode, lastError, nextAttempt)
+	return err
+}
+
+// GetAlertDeliveries retrieves the delivery log of an alert rule, newest
+// first
+func (c *PostgresClient) GetAlertDeliveries(ctx context.Context, alertID string, limit int) ([]models.AlertDelivery, error) {
+	rows, err := c.pool.Query(ctx, `
+		SELECT id, alert_id, payload, status, attempts, COALESCE(last_status_code, 0), COALESCE(last_error, ''),
+		       CASE WHEN status = 'pending' THEN next_attempt_at END, created_at, delivered_at
+		FROM alert_deliveries
+		WHERE alert_id = $1
+		ORDER BY created_at DESC
+		LIMIT $2
+	`, alertID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var deliveries []models.AlertDelivery
+	for rows.Next() {
+		var delivery models.AlertDelivery
+		var payload []byte
+		if err := rows.Scan(
+			&delivery.ID, &delivery.AlertID, &payload, &delivery.Status, &delivery.Attempts,
+			&delivery.LastStatusCode, &delivery.LastError, &delivery.NextAttemptAt, &delivery.CreatedAt, &delivery.DeliveredAt,
+		); err != nil {
+			return nil, err
+		}
+		delivery.Payload = payload
+		deliveries = append(deliveries, delivery)
+	}
+
+	return deliveries, rows.Err()
+}
@@ -0,0 +1,128 @@
+package database
+
+import (
+	"context"
+	"sort"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/sosadtsia/bike-parts-finder/pkg/models"
+)
+
+// GetCategories retrieves all taxonomy nodes with the number of parts
+// mapped directly to each of them
+func (c *PostgresClient) GetCategories(ctx context.Context) ([]models.Category, error) {
+	rows, err := c.pool.Query(ctx, `
+		SELECT c.id, c.name, COALESCE(c.parent_id, ''), c.position, COUNT(p.id)
+		FROM categories c
+		LEFT JOIN parts p ON p.category_id = c.id
+		GROUP BY c.id
+		ORDER BY c.position, c.name
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var categories []models.Category
+	for rows.Next() {
+		var category models.Category
+		if err := rows.Scan(&category.ID, &category.Name, &category.ParentID, &category.Position, &category.PartCount); err != nil {
+			return nil, err
+		}
+		categories = append(categories, category)
+	}
+
+	return categories, rows.Err()
+}
+
+// GetCategoryMappings retrieves the rules mapping retailer breadcrumbs to
+// taxonomy nodes
+func (c *PostgresClient) GetCategoryMappings(ctx context.Context) ([]models.CategoryMapping, error) {
+	rows, err := c.pool.Query(ctx, "SELECT source, breadcrumbs, category_id FROM category_mappings ORDER BY id")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var mappings []models.CategoryMapping
+	for rows.Next() {
+		var mapping models.CategoryMapping
+		if err := rows.Scan(&mapping.Source, &mapping.Breadcrumbs, &mapping.CategoryID); err != nil {
+			return nil, err
+		}
+		mappings = append(mappings, mapping)
+	}
+
+	return mappings, rows.Err()
+}
+
+// RecordUnmappedCategory records that a part of source had breadcrumbs no
+// mapping rule matched
+func (c *PostgresClient) RecordUnmappedCategory(ctx context.Context, source string, breadcrumbs []string, url string) error {
+	return c.RecordUnmappedCategories(ctx, []models.UnmappedCategory{
+		{Source: source, Breadcrumbs: breadcrumbs, Occurrences: 1, ExampleURL: url},
+	})
+}
+
+// RecordUnmappedCategories records breadcrumbs no mapping rule matched,
+// each with how often it was seen and an example URL, in one round trip.
+// Repeated breadcrumbs of a source are merged.
+func (c *PostgresClient) RecordUnmappedCategories(ctx context.Context, unmapped []models.UnmappedCategory) error {
+	merged := make(map[string]*models.UnmappedCategory)
+	var keys []string
+	for _, u := range unmapped {
+		key := u.Source + "\x00" + strings.Join(u.Breadcrumbs, "\x00")
+		if m, ok := merged[key]; ok {
+			m.Occurrences += u.Occurrences
+			m.ExampleURL = u.ExampleURL
+			continue
+		}
+		merged[key] = &u
+		keys = append(keys, key)
+	}
+	if len(keys) == 0 {
+		return nil
+	}
+
+	// Upsert in key order so that concurrent batches don't deadlock
+	sort.Strings(keys)
+	batch := &pgx.Batch{}
+	for _, key := range keys {
+		u := merged[key]
+		batch.Queue(`
+			INSERT INTO unmapped_categories (source, breadcrumbs, example_url, occurrences)
+			VALUES ($1, $2, $3, $4)
+			ON CONFLICT (source, breadcrumbs) DO UPDATE SET
+				occurrences = unmapped_categories.occurrences + $4,
+				example_url = $3,
+				last_seen = NOW()
+		`, u.Source, u.Breadcrumbs, u.ExampleURL, max(u.Occurrences, 1))
+	}
+	return c.pool.SendBatch(ctx, batch).Close()
+}
+
+// GetUnmappedCategories retrieves the unmapped breadcrumbs, most frequent
+// first
+func (c *PostgresClient) GetUnmappedCategories(ctx context.Context) ([]models.UnmappedCategory, error) {
+	rows, err := c.pool.Query(ctx, `
+		SELECT source, breadcrumbs, occurrences, example_url, first_seen, last_seen
+		FROM unmapped_categories
+		ORDER BY occurrences DESC, last_seen DESC
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var unmapped []models.UnmappedCategory
+	for rows.Next() {
+		var u models.UnmappedCategory
+		if err := rows.Scan(&u.Source, &u.Breadcrumbs, &u.Occurrences, &u.ExampleURL, &u.FirstSeen, &u.LastSeen); err != nil {
+			return nil, err
+		}
+		unmapped = append(unmapped, u)
+	}
+
+	return unmapped, rows.Err()
+}
@@ -0,0 +1,411 @@
+package database
+
+import (
+	"context"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sosadtsia/bike-parts-finder/pkg/models"
+)
+
+// MemoryPartRepository is a PartRepository kept in memory, for tests and
+// local development without Postgres. It filters, orders and paginates
+// like PostgresClient but leaves text search ranking to Postgres.
+type MemoryPartRepository struct {
+	mu           sync.RWMutex
+	parts        map[string]models.Part
+	observations map[string][]models.PriceObservation
+}
+
+var _ PartRepository = (*MemoryPartRepository)(nil)
+
+// NewMemoryPartRepository creates an empty in-memory part repository
+func NewMemoryPartRepository() *MemoryPartRepository {
+	return &MemoryPartRepository{
+		parts:        make(map[string]models.Part),
+		observations: make(map[string][]models.PriceObservation),
+	}
+}
+
+// StorePart stores a part, replacing a stored part with the same ID but
+// keeping its creation time
+func (r *MemoryPartRepository) StorePart(ctx context.Context, part models.Part) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	if part.CreatedAt.IsZero() {
+		part.CreatedAt = now
+	}
+	if part.UpdatedAt.IsZero() {
+		part.UpdatedAt = now
+	}
+	if existing, ok := r.parts[part.ID]; ok {
+		part.CreatedAt = existing.CreatedAt
+	}
+	part.Discount = discountOf(part)
+	part.Specs = uniqueSpecs(part.Specs)
+
+	r.parts[part.ID] = clonePart(part)
+	return nil
+}
+
+// StoreParts stores parts all at once
+func (r *MemoryPartRepository) StoreParts(ctx context.Context, parts []models.Part) error {
+	for _, part := range parts {
+		if err := r.StorePart(ctx, part); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetPartByID retrieves a part by ID
+func (r *MemoryPartRepository) GetPartByID(ctx context.Context, id string) (models.Part, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	part, ok := r.parts[id]
+	if !ok {
+		return models.Part{}, ErrPartNotFound
+	}
+	return clonePart(part), nil
+}
+
+// GetParts retrieves a page of parts, newest first
+func (r *MemoryPartRepository) GetParts(ctx context.Context, offset, limit int) ([]models.Part, error) {
+	result, err := r.SearchParts(ctx, SearchOptions{Offset: offset, Limit: limit})
+	return result.Parts, err
+}
+
+// GetPartsByGTIN retrieves the parts with the given normalized GTIN,
+// cheapest first
+func (r *MemoryPartRepository) GetPartsByGTIN(ctx context.Context, gtin string) ([]models.Part, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var parts []models.Part
+	for _, part := range r.parts {
+		if part.GTIN == gtin {
+			parts = append(parts, listedPart(part))
+		}
+	}
+	sort.SliceStable(parts, func(i, j int) bool { return parts[i].Price < parts[j].Price })
+
+	return parts, nil
+}
+
+// SearchParts searches for parts with the filters and orders of
+// PostgresClient. Queries match parts whose brand, model or description
+// contain every word; results are not ranked by relevance, matched
+// fuzzily, highlighted or faceted.
+func (r *MemoryPartRepository) SearchParts(ctx context.Context, opts SearchOptions) (SearchResult, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var parts []models.Part
+	for _, part := range r.parts {
+		if matchesSearch(part, opts) {
+			parts = append(parts, part)
+		}
+	}
+
+	sort.SliceStable(parts, func(i, j int) bool {
+		a, b := parts[i], parts[j]
+		switch {
+		case opts.SortSpec != "":
+			av, aok := minSpecValue(a, opts.SortSpec)
+			bv, bok := minSpecValue(b, opts.SortSpec)
+			switch {
+			case aok != bok:
+				return aok
+			case aok && av != bv:
+				if opts.SortDesc {
+					return av > bv
+				}
+				return av < bv
+			}
+		case opts.Sort == SortPrice || opts.Sort == SortDiscount || opts.Sort == SortRating:
+			if less, ok := compareParts(a, b, opts.Sort, opts.SortDesc); ok {
+				return less
+			}
+		}
+		if !a.CreatedAt.Equal(b.CreatedAt) {
+			return a.CreatedAt.After(b.CreatedAt)
+		}
+		return a.ID < b.ID
+	})
+
+	var result SearchResult
+	for _, part := range paginate(parts, opts.Offset, opts.Limit) {
+		part = listedPart(part)
+		if opts.Summary {
+			part.Specs, part.Images = nil, nil
+		}
+		result.Parts = append(result.Parts, part)
+	}
+
+	return result, nil
+}
+
+// HasSpecKey reports whether any part has a spec with a key
+func (r *MemoryPartRepository) HasSpecKey(ctx context.Context, key string) (bool, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, part := range r.parts {
+		for _, spec := range part.Specs {
+			if spec.Key == key {
+				return true, nil
+			}
+		}
+	}
+	return false, nil
+}
+
+// RecordPriceObservation records the price and stock of a part unless they
+// are unchanged since its last observation. A part without a price keeps
+// the last observed price.
+func (r *MemoryPartRepository) RecordPriceObservation(ctx context.Context, part models.Part) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	observation := models.PriceObservation{
+		Price:      math.Round(part.Price*100) / 100,
+		MSRP:       math.Round(part.MSRP*100) / 100,
+		Currency:   part.Currency,
+		InStock:    part.InStock,
+		ObservedAt: time.Now(),
+	}
+
+	history := r.observations[part.ID]
+	if observation.Price <= 0 {
+		// Keep the last known price so that a change in stock is recorded
+		if len(history) == 0 {
+			return false, nil
+		}
+		last := history[len(history)-1]
+		observation.Price, observation.MSRP, observation.Currency = last.Price, last.MSRP, last.Currency
+	}
+	if n := len(history); n > 0 {
+		last := history[n-1]
+		if last.Price == observation.Price && last.MSRP == observation.MSRP &&
+			last.Currency == observation.Currency && last.InStock == observation.InStock {
+			return false, nil
+		}
+	}
+	r.observations[part.ID] = append(history, observation)
+
+	return true, nil
+}
+
+// RecordPriceObservations records the prices and stock of parts that
+// changed since their last observations and returns how many were recorded
+func (r *MemoryPartRepository) RecordPriceObservations(ctx context.Context, parts []models.Part) (int, error) {
+	recorded := 0
+	for _, part := range parts {
+		ok, err := r.RecordPriceObservation(ctx, part)
+		if err != nil {
+			return recorded, err
+		}
+		if ok {
+			recorded++
+		}
+	}
+	return recorded, nil
+}
+
+// GetPriceObservations retrieves the observations of a part since a time
+// in chronological order, starting with the last observation before it
+func (r *MemoryPartRepository) GetPriceObservations(ctx context.Context, partID string, since time.Time) ([]models.PriceObservation, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	history := r.observations[partID]
+	start := sort.Search(len(history), func(i int) bool { return !history[i].ObservedAt.Before(since) })
+	if start > 0 {
+		start--
+	}
+
+	var observations []models.PriceObservation
+	observations = append(observations, history[start:]...)
+	return observations, nil
+}
+
+// matchesSearch reports whether a part passes the filters of a search
+func matchesSearch(part models.Part, opts SearchOptions) bool {
+	if opts.Query != "" {
+		text := strings.ToLower(part.Brand + " " + part.Model + " " + part.Description)
+		for _, term := range queryTerms(opts.Query) {
+			if !strings.Contains(text, term) {
+				return false
+			}
+		}
+	}
+	if opts.Brand != "" && !containsFold(part.Brand, opts.Brand) {
+		return false
+	}
+	if opts.Category != "" && !containsFold(part.Category, opts.Category) {
+		return false
+	}
+	if opts.SubCategory != "" && !containsFold(part.SubCategory, opts.SubCategory) {
+		return false
+	}
+	if opts.Source != "" && !strings.EqualFold(part.Source, opts.Source) {
+		return false
+	}
+	if opts.InStock != nil && part.InStock != *opts.InStock {
+		return false
+	}
+	if opts.MinPrice != nil && part.Price < *opts.MinPrice {
+		return false
+	}
+	if opts.MaxPrice != nil && part.Price > *opts.MaxPrice {
+		return false
+	}
+	if opts.MinDiscount != nil && discountOf(part) < *opts.MinDiscount {
+		return false
+	}
+	if opts.MinRating != nil && part.Rating < *opts.MinRating {
+		return false
+	}
+
+	for _, filter := range opts.Specs {
+		matched := false
+		for _, spec := range part.Specs {
+			if spec.Key != filter.Key {
+				continue
+			}
+			if filter.Value != "" && !strings.EqualFold(spec.Value, filter.Value) {
+				continue
+			}
+			// Like SQL comparisons with NULL, bounds exclude specs
+			// without a numeric value
+			if (filter.Min != nil || filter.Max != nil) && spec.NumericValue == nil {
+				continue
+			}
+			if filter.Min != nil && *spec.NumericValue < *filter.Min {
+				continue
+			}
+			if filter.Max != nil && *spec.NumericValue > *filter.Max {
+				continue
+			}
+			matched = true
+			break
+		}
+		if !matched {
+			return false
+		}
+	}
+
+	return true
+}
+
+// compareParts reports whether a comes before b when ordered by price,
+// discount or rating, and false for ok if they are tied. Only the price
+// order is reversed by desc; discounts and ratings are highest first.
+func compareParts(a, b models.Part, order string, desc bool) (less bool, ok bool) {
+	var av, bv float64
+	switch order {
+	case SortPrice:
+		av, bv = a.Price, b.Price
+	case SortDiscount:
+		av, bv, desc = discountOf(a), discountOf(b), true
+	case SortRating:
+		av, bv, desc = a.Rating, b.Rating, true
+		if av == bv {
+			av, bv = float64(a.NumReviews), float64(b.NumReviews)
+		}
+	}
+	if av == bv {
+		return false, false
+	}
+	if desc {
+		return av > bv, true
+	}
+	return av < bv, true
+}
+
+// discountOf returns the discount of a part off its MSRP in percent, like
+// the discount Postgres computes
+func discountOf(part models.Part) float64 {
+	if part.MSRP > part.Price {
+		return (part.MSRP - part.Price) / part.MSRP * 100
+	}
+	return 0
+}
+
+// minSpecValue returns the lowest numeric value of the specs with a key
+func minSpecValue(part models.Part, key string) (float64, bool) {
+	var value float64
+	found := false
+	for _, spec := range part.Specs {
+		if spec.Key == key && spec.NumericValue != nil && (!found || *spec.NumericValue < value) {
+			value, found = *spec.NumericValue, true
+		}
+	}
+	return value, found
+}
+
+// containsFold reports whether substr is within s, ignoring case
+func containsFold(s, substr string) bool {
+	return strings.Contains(strings.ToLower(s), strings.ToLower(substr))
+}
+
+// paginate returns the page of parts starting at offset with at most
+// limit parts
+func paginate(parts []models.Part, offset, limit int) []models.Part {
+	if offset >= len(parts) {
+		return nil
+	}
+	parts = parts[offset:]
+	if limit >= 0 && limit < len(parts) {
+		parts = parts[:limit]
+	}
+	if len(parts) == 0 {
+		return nil
+	}
+	return parts
+}
+
+// listedPart returns a copy of a part as listed in search results, which
+// like PostgresClient leave out variants
+func listedPart(part models.Part) models.Part {
+	part = clonePart(part)
+	part.Variants = nil
+	return part
+}
+
+// clonePart returns a copy of a part that shares no slices or maps with it
+func clonePart(part models.Part) models.Part {
+	part.Breadcrumbs = append([]string(nil), part.Breadcrumbs...)
+	part.Images = append([]string(nil), part.Images...)
+
+	specs := part.Specs
+	part.Specs = nil
+	for _, spec := range specs {
+		if spec.NumericValue != nil {
+			value := *spec.NumericValue
+			spec.NumericValue = &value
+		}
+		part.Specs = append(part.Specs, spec)
+	}
+
+	variants := part.Variants
+	part.Variants = nil
+	for _, variant := range variants {
+		if variant.Attributes != nil {
+			attributes := make(map[string]string, len(variant.Attributes))
+			for name, value := range variant.Attributes {
+				attributes[name] = value
+			}
+			variant.Attributes = attributes
+		}
+		part.Variants = append(part.Variants, variant)
+	}
+
+	return part
+}
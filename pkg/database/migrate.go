@@ -0,0 +1,375 @@
+package database
+
+import (
+	"context"
+	"crypto/sha256"
+	"embed"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// migrationFiles holds the schema migrations, named
+// <version>_<name>.up.sql and <version>_<name>.down.sql
+//
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+// migrationLockPoll is how often a migration lock held by another process
+// is polled for
+const migrationLockPoll = time.Second
+
+// migrationLockKey is the key of the Postgres advisory lock held while
+// migrating
+const migrationLockKey int64 = 0x6270665f6d696772
+
+// ErrMigrationLocked is returned when the migration lock could not be
+// acquired in time
+var ErrMigrationLocked = errors.New("migrations are locked by another process")
+
+// Migration is a versioned schema change
+type Migration struct {
+	Version  int
+	Name     string
+	Up       string
+	Down     string
+	Checksum string
+}
+
+// MigrationStatus is a migration and when it was applied, if it was
+type MigrationStatus struct {
+	Migration
+	AppliedAt *time.Time
+}
+
+// migrationPattern matches migration file names
+var migrationPattern = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+
+// LoadMigrations reads the migrations in a directory ordered by version.
+// Every migration needs an up file; down files are optional.
+func LoadMigrations(fsys fs.FS, dir string) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, fmt.Errorf("reading migrations: %w", err)
+	}
+
+	byVersion := make(map[int]*Migration)
+	for _, entry := range entries {
+		match := migrationPattern.FindStringSubmatch(entry.Name())
+		if match == nil {
+			return nil, fmt.Errorf("unexpected migration file %s", entry.Name())
+		}
+		version, _ := strconv.Atoi(match[1])
+
+		data, err := fs.ReadFile(fsys, path.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("reading migration %s: %w", entry.Name(), err)
+		}
+
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = m
+		} else if m.Name != match[2] {
+			return nil, fmt.Errorf("migration version %d is used by %s and %s", version, m.Name, match[2])
+		}
+		if match[3] == "up" {
+			m.Up = string(data)
+			sum := sha256.Sum256(data)
+			m.Checksum = hex.EncodeToString(sum[:])
+		} else {
+			m.Down = string(data)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.Up == "" {
+			return nil, fmt.Errorf("migration %d_%s has no up file", m.Version, m.Name)
+		}
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+
+	return migrations, nil
+}
+
+// Migrator applies and reverts schema migrations. Only one migrator at a
+// time changes the schema; others wait for a Postgres advisory lock, which
+// is held for as long as the migration runs and released by the server if
+// the process dies.
+type Migrator struct {
+	pool        *pgxpool.Pool
+	migrations  []Migration
+	LockTimeout time.Duration
+	Logf        func(format string, args ...any)
+}
+
+// NewMigrator creates a migrator for the migrations embedded in the binary
+func (c *PostgresClient) NewMigrator() (*Migrator, error) {
+	migrations, err := LoadMigrations(migrationFiles, "migrations")
+	if err != nil {
+		return nil, err
+	}
+	return &Migrator{
+		pool:        c.pool,
+		migrations:  migrations,
+		LockTimeout: 2 * time.Minute,
+		Logf:        func(string, ...any) {},
+	}, nil
+}
+
+// AutoMigrate reports whether services apply pending migrations when they
+// start, which DATABASE_AUTO_MIGRATE=false turns off
+func AutoMigrate() bool {
+	v, err := strconv.ParseBool(os.Getenv("DATABASE_AUTO_MIGRATE"))
+	return err != nil || v
+}
+
+// Migrate applies all pending migrations
+func (c *PostgresClient) Migrate(ctx context.Context) error {
+	m, err := c.NewMigrator()
+	if err != nil {
+		return err
+	}
+	_, err = m.Up(ctx)
+	return err
+}
+
+// ExecScript runs a SQL script of one or more statements, such as sample
+// data, in a transaction
+func (c *PostgresClient) ExecScript(ctx context.Context, script string) error {
+	tx, err := c.pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, script); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+// Up applies pending migrations in order, each in its own transaction, and
+// returns how many were applied. It fails without changes if an applied
+// migration was edited since.
+func (m *Migrator) Up(ctx context.Context) (int, error) {
+	var count int
+	err := m.withLock(ctx, func() error {
+		applied, err := m.applied(ctx)
+		if err != nil {
+			return err
+		}
+		if err := m.verify(applied); err != nil {
+			return err
+		}
+
+		for _, migration := range m.migrations {
+			if _, ok := applied[migration.Version]; ok {
+				continue
+			}
+			m.Logf("Applying migration %d_%s", migration.Version, migration.Name)
+			err := m.inTx(ctx, func(tx pgx.Tx) error {
+				if _, err := tx.Exec(ctx, migration.Up); err != nil {
+					return err
+				}
+				_, err := tx.Exec(ctx, `
+					INSERT INTO schema_migrations (version, name, checksum) VALUES ($1, $2, $3)
+				`, migration.Version, migration.Name, migration.Checksum)
+				return err
+			})
+			if err != nil {
+				return fmt.Errorf("applying migration %d_%s: %w", migration.Version, migration.Name, err)
+			}
+			count++
+		}
+		return nil
+	})
+	return count, err
+}
+
+// Down reverts the latest steps applied migrations and returns how many
+// were reverted
+func (m *Migrator) Down(ctx context.Context, steps int) (int, error) {
+	var count int
+	err := m.withLock(ctx, func() error {
+		applied, err := m.applied(ctx)
+		if err != nil {
+			return err
+		}
+		if err := m.verify(applied); err != nil {
+			return err
+		}
+
+		for i := len(m.migrations) - 1; i >= 0 && count < steps; i-- {
+			migration := m.migrations[i]
+			if _, ok := applied[migration.Version]; !ok {
+				continue
+			}
+			if migration.Down == "" {
+				return fmt.Errorf("migration %d_%s cannot be reverted: it has no down file", migration.Version, migration.Name)
+			}
+			m.Logf("Reverting migration %d_%s", migration.Version, migration.Name)
+			err := m.inTx(ctx, func(tx pgx.Tx) error {
+				if _, err := tx.Exec(ctx, migration.Down); err != nil {
+					return err
+				}
+				_, err := tx.Exec(ctx, "DELETE FROM schema_migrations WHERE version = $1", migration.Version)
+				return err
+			})
+			if err != nil {
+				return fmt.Errorf("reverting migration %d_%s: %w", migration.Version, migration.Name, err)
+			}
+			count++
+		}
+		return nil
+	})
+	return count, err
+}
+
+// Status returns every known migration with when it was applied
+func (m *Migrator) Status(ctx context.Context) ([]MigrationStatus, error) {
+	if err := m.ensureTables(ctx); err != nil {
+		return nil, err
+	}
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]MigrationStatus, 0, len(m.migrations))
+	for _, migration := range m.migrations {
+		status := MigrationStatus{Migration: migration}
+		if a, ok := applied[migration.Version]; ok {
+			status.AppliedAt = &a.appliedAt
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses, m.verify(applied)
+}
+
+// appliedMigration is a row of schema_migrations
+type appliedMigration struct {
+	name      string
+	checksum  string
+	appliedAt time.Time
+}
+
+// applied returns the applied migrations by version
+func (m *Migrator) applied(ctx context.Context) (map[int]appliedMigration, error) {
+	rows, err := m.pool.Query(ctx, "SELECT version, name, checksum, applied_at FROM schema_migrations")
+	if err != nil {
+		return nil, fmt.Errorf("reading applied migrations: %w", err)
+	}
+	defer rows.Close()
+
+	applied := make(map[int]appliedMigration)
+	for rows.Next() {
+		var version int
+		var a appliedMigration
+		if err := rows.Scan(&version, &a.name, &a.checksum, &a.appliedAt); err != nil {
+			return nil, fmt.Errorf("reading applied migrations: %w", err)
+		}
+		applied[version] = a
+	}
+	return applied, rows.Err()
+}
+
+// verify checks that applied migrations were not edited since they were
+// applied
+func (m *Migrator) verify(applied map[int]appliedMigration) error {
+	for _, migration := range m.migrations {
+		a, ok := applied[migration.Version]
+		if ok && a.checksum != migration.Checksum {
+			return fmt.Errorf("migration %d_%s was changed after it was applied; add a new migration instead", migration.Version, migration.Name)
+		}
+	}
+	return nil
+}
+
+// ensureTables creates the migration bookkeeping table
+func (m *Migrator) ensureTables(ctx context.Context) error {
+	_, err := m.pool.Exec(ctx, `
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version INTEGER PRIMARY KEY,
+			name VARCHAR(255) NOT NULL,
+			checksum VARCHAR(64) NOT NULL,
+			applied_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+		)
+	`)
+	if err != nil {
+		return fmt.Errorf("creating migration table: %w", err)
+	}
+	return nil
+}
+
+// withLock runs fn holding the migration lock, waiting up to LockTimeout
+// for another process to release it. The advisory lock belongs to the
+// session, so it is taken on a connection reserved until fn returns.
+func (m *Migrator) withLock(ctx context.Context, fn func() error) error {
+	conn, err := m.pool.Acquire(ctx)
+	if err != nil {
+		return fmt.Errorf("acquiring migration lock connection: %w", err)
+	}
+	defer conn.Release()
+
+	deadline := time.Now().Add(m.LockTimeout)
+	for {
+		var locked bool
+		if err := conn.QueryRow(ctx, "SELECT pg_try_advisory_lock($1)", migrationLockKey).Scan(&locked); err != nil {
+			return fmt.Errorf("acquiring migration lock: %w", err)
+		}
+		if locked {
+			break
+		}
+
+		if time.Now().After(deadline) {
+			return ErrMigrationLocked
+		}
+		m.Logf("Waiting for migration lock")
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(migrationLockPoll):
+		}
+	}
+
+	defer func() {
+		// Release the lock even if ctx was cancelled while migrating
+		if _, err := conn.Exec(context.Background(), "SELECT pg_advisory_unlock($1)", migrationLockKey); err != nil {
+			m.Logf("Error releasing migration lock: %v", err)
+			// Closing the connection ends the session and its locks
+			conn.Conn().Close(context.Background())
+		}
+	}()
+
+	if err := m.ensureTables(ctx); err != nil {
+		return err
+	}
+	return fn()
+}
+
+// inTx runs fn in a transaction, committing it if fn succeeds
+func (m *Migrator) inTx(ctx context.Context, fn func(pgx.Tx) error) error {
+	tx, err := m.pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if err := fn(tx); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
@@ -0,0 +1,85 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+func TestEmbeddedMigrations(t *testing.T) {
+	migrations, err := LoadMigrations(migrationFiles, "migrations")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, m := range migrations {
+		if m.Version != i+1 {
+			t.Errorf("migration %d_%s has version %d, want %d", m.Version, m.Name, m.Version, i+1)
+		}
+		if m.Down == "" {
+			t.Errorf("migration %d_%s has no down file", m.Version, m.Name)
+		}
+	}
+}
+
+func TestLoadMigrations(t *testing.T) {
+	fsys := fstest.MapFS{
+		"m/0002_add_index.up.sql":   {Data: []byte("CREATE INDEX i ON t (c);")},
+		"m/0001_initial.up.sql":     {Data: []byte("CREATE TABLE t (c INT);")},
+		"m/0001_initial.down.sql":   {Data: []byte("DROP TABLE t;")},
+		"m/0002_add_index.down.sql": {Data: []byte("DROP INDEX i;")},
+	}
+	migrations, err := LoadMigrations(fsys, "m")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(migrations) != 2 || migrations[0].Name != "initial" || migrations[1].Name != "add_index" {
+		t.Fatalf("got %+v, want initial and add_index", migrations)
+	}
+	if migrations[0].Down != "DROP TABLE t;" || migrations[0].Checksum == "" {
+		t.Errorf("got %+v, want the down script and a checksum", migrations[0])
+	}
+
+	invalid := []fstest.MapFS{
+		{"m/0001_initial.down.sql": {Data: []byte("DROP TABLE t;")}},
+		{"m/0001_a.up.sql": {}, "m/0001_b.up.sql": {}},
+		{"m/initial.sql": {}},
+	}
+	for _, fsys := range invalid {
+		if _, err := LoadMigrations(fsys, "m"); err == nil {
+			t.Errorf("loaded invalid migrations %v", fsys)
+		}
+	}
+}
+
+func TestMigrationLock(t *testing.T) {
+	c := newTestClient(t)
+	ctx := context.Background()
+
+	holder, err := c.NewMigrator()
+	if err != nil {
+		t.Fatal(err)
+	}
+	waiter, err := c.NewMigrator()
+	if err != nil {
+		t.Fatal(err)
+	}
+	waiter.LockTimeout = 0
+
+	// A migration holding the lock for longer than any timeout keeps it
+	err = holder.withLock(ctx, func() error {
+		if _, err := waiter.Up(ctx); !errors.Is(err, ErrMigrationLocked) {
+			t.Errorf("got %v while the lock was held, want ErrMigrationLocked", err)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	waiter.LockTimeout = time.Second
+	if _, err := waiter.Up(ctx); err != nil {
+		t.Errorf("got %v after the lock was released", err)
+	}
+}
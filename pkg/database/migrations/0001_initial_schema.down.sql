@@ -0,0 +1,9 @@
+DROP VIEW IF EXISTS complete_parts;
+DROP VIEW IF EXISTS parts_with_images;
+DROP VIEW IF EXISTS parts_with_specs;
+DROP TRIGGER IF EXISTS update_parts_updated_at ON parts;
+DROP FUNCTION IF EXISTS update_updated_at_column();
+DROP TABLE IF EXISTS scrape_requests;
+DROP TABLE IF EXISTS part_images;
+DROP TABLE IF EXISTS part_specs;
+DROP TABLE IF EXISTS parts;
@@ -0,0 +1,6 @@
+DROP INDEX IF EXISTS idx_parts_mpn;
+DROP INDEX IF EXISTS idx_parts_gtin;
+
+ALTER TABLE parts DROP COLUMN IF EXISTS sku;
+ALTER TABLE parts DROP COLUMN IF EXISTS mpn;
+ALTER TABLE parts DROP COLUMN IF EXISTS gtin;
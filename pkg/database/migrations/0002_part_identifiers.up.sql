@@ -0,0 +1,7 @@
+-- Product identifiers used to match the same part across retailers
+ALTER TABLE parts ADD COLUMN IF NOT EXISTS gtin VARCHAR(14);
+ALTER TABLE parts ADD COLUMN IF NOT EXISTS mpn VARCHAR(100);
+ALTER TABLE parts ADD COLUMN IF NOT EXISTS sku VARCHAR(100);
+
+CREATE INDEX IF NOT EXISTS idx_parts_gtin ON parts(gtin);
+CREATE INDEX IF NOT EXISTS idx_parts_mpn ON parts(mpn);
@@ -0,0 +1,9 @@
+DROP INDEX IF EXISTS idx_parts_category_id;
+
+DROP TABLE IF EXISTS unmapped_categories;
+DROP TABLE IF EXISTS category_mappings;
+
+ALTER TABLE parts DROP COLUMN IF EXISTS breadcrumbs;
+ALTER TABLE parts DROP COLUMN IF EXISTS category_id;
+
+DROP TABLE IF EXISTS categories;
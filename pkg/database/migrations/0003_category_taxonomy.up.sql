@@ -0,0 +1,115 @@
+-- Canonical category taxonomy
+CREATE TABLE IF NOT EXISTS categories (
+    id VARCHAR(100) PRIMARY KEY,
+    name VARCHAR(100) NOT NULL,
+    parent_id VARCHAR(100) REFERENCES categories(id) ON DELETE CASCADE,
+    position INTEGER NOT NULL DEFAULT 0
+);
+
+ALTER TABLE parts ADD COLUMN IF NOT EXISTS category_id VARCHAR(100) REFERENCES categories(id) ON DELETE SET NULL;
+ALTER TABLE parts ADD COLUMN IF NOT EXISTS breadcrumbs TEXT[];
+
+-- Rules mapping retailer breadcrumbs to taxonomy nodes; source '*' applies
+-- to every retailer
+CREATE TABLE IF NOT EXISTS category_mappings (
+    id SERIAL PRIMARY KEY,
+    source VARCHAR(100) NOT NULL DEFAULT '*',
+    breadcrumbs TEXT[] NOT NULL,
+    category_id VARCHAR(100) NOT NULL REFERENCES categories(id) ON DELETE CASCADE,
+    CONSTRAINT unique_category_mapping UNIQUE (source, breadcrumbs)
+);
+
+-- Retailer breadcrumbs no mapping rule matched
+CREATE TABLE IF NOT EXISTS unmapped_categories (
+    source VARCHAR(100) NOT NULL,
+    breadcrumbs TEXT[] NOT NULL,
+    occurrences INTEGER NOT NULL DEFAULT 1,
+    example_url VARCHAR(2048) NOT NULL,
+    first_seen TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    last_seen TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (source, breadcrumbs)
+);
+
+CREATE INDEX IF NOT EXISTS idx_parts_category_id ON parts(category_id);
+
+-- Taxonomy nodes
+INSERT INTO categories (id, name, parent_id, position)
+VALUES
+    ('drivetrain', 'Drivetrain', NULL, 1),
+    ('drivetrain/cassettes', 'Cassettes', 'drivetrain', 1),
+    ('drivetrain/chains', 'Chains', 'drivetrain', 2),
+    ('drivetrain/chainrings', 'Chainrings', 'drivetrain', 3),
+    ('drivetrain/cranksets', 'Cranksets', 'drivetrain', 4),
+    ('drivetrain/derailleurs', 'Derailleurs', 'drivetrain', 5),
+    ('drivetrain/shifters', 'Shifters', 'drivetrain', 6),
+    ('drivetrain/bottom-brackets', 'Bottom Brackets', 'drivetrain', 7),
+    ('brakes', 'Brakes', NULL, 2),
+    ('brakes/disc-brakes', 'Disc Brakes', 'brakes', 1),
+    ('brakes/rotors', 'Rotors', 'brakes', 2),
+    ('brakes/pads', 'Brake Pads', 'brakes', 3),
+    ('brakes/rim-brakes', 'Rim Brakes', 'brakes', 4),
+    ('suspension', 'Suspension', NULL, 3),
+    ('suspension/forks', 'Forks', 'suspension', 1),
+    ('suspension/rear-shocks', 'Rear Shocks', 'suspension', 2),
+    ('wheels', 'Wheels & Tires', NULL, 4),
+    ('wheels/wheelsets', 'Wheelsets', 'wheels', 1),
+    ('wheels/hubs', 'Hubs', 'wheels', 2),
+    ('wheels/rims', 'Rims', 'wheels', 3),
+    ('wheels/tires', 'Tires', 'wheels', 4),
+    ('wheels/tubes', 'Tubes', 'wheels', 5),
+    ('cockpit', 'Cockpit', NULL, 5),
+    ('cockpit/handlebars', 'Handlebars', 'cockpit', 1),
+    ('cockpit/stems', 'Stems', 'cockpit', 2),
+    ('cockpit/grips', 'Grips', 'cockpit', 3),
+    ('cockpit/headsets', 'Headsets', 'cockpit', 4),
+    ('seating', 'Seating', NULL, 6),
+    ('seating/saddles', 'Saddles', 'seating', 1),
+    ('seating/seatposts', 'Seatposts', 'seating', 2),
+    ('pedals', 'Pedals', NULL, 7),
+    ('tools', 'Tools & Maintenance', NULL, 8)
+ON CONFLICT (id) DO NOTHING;
+
+-- Mapping rules that hold for most retailers; add per-source rules for
+-- breadcrumbs listed in unmapped_categories
+INSERT INTO category_mappings (source, breadcrumbs, category_id)
+VALUES
+    ('*', '{Drivetrain}', 'drivetrain'),
+    ('*', '{Cassettes}', 'drivetrain/cassettes'),
+    ('*', '{Chains}', 'drivetrain/chains'),
+    ('*', '{Chainrings}', 'drivetrain/chainrings'),
+    ('*', '{Cranks}', 'drivetrain/cranksets'),
+    ('*', '{Cranksets}', 'drivetrain/cranksets'),
+    ('*', '{Derailleurs}', 'drivetrain/derailleurs'),
+    ('*', '{"Rear Derailleurs"}', 'drivetrain/derailleurs'),
+    ('*', '{Shifters}', 'drivetrain/shifters'),
+    ('*', '{"Bottom Brackets"}', 'drivetrain/bottom-brackets'),
+    ('*', '{Brakes}', 'brakes'),
+    ('*', '{"Disc Brakes"}', 'brakes/disc-brakes'),
+    ('*', '{Rotors}', 'brakes/rotors'),
+    ('*', '{"Disc Brake Rotors"}', 'brakes/rotors'),
+    ('*', '{"Brake Pads"}', 'brakes/pads'),
+    ('*', '{"Rim Brakes"}', 'brakes/rim-brakes'),
+    ('*', '{Suspension}', 'suspension'),
+    ('*', '{Forks}', 'suspension/forks'),
+    ('*', '{"Suspension Forks"}', 'suspension/forks'),
+    ('*', '{Shocks}', 'suspension/rear-shocks'),
+    ('*', '{"Rear Shocks"}', 'suspension/rear-shocks'),
+    ('*', '{Wheels}', 'wheels'),
+    ('*', '{Wheelsets}', 'wheels/wheelsets'),
+    ('*', '{Hubs}', 'wheels/hubs'),
+    ('*', '{Rims}', 'wheels/rims'),
+    ('*', '{Tires}', 'wheels/tires'),
+    ('*', '{Tyres}', 'wheels/tires'),
+    ('*', '{Tubes}', 'wheels/tubes'),
+    ('*', '{Cockpit}', 'cockpit'),
+    ('*', '{Handlebars}', 'cockpit/handlebars'),
+    ('*', '{Stems}', 'cockpit/stems'),
+    ('*', '{Grips}', 'cockpit/grips'),
+    ('*', '{Headsets}', 'cockpit/headsets'),
+    ('*', '{Saddles}', 'seating/saddles'),
+    ('*', '{Seatposts}', 'seating/seatposts'),
+    ('*', '{"Dropper Posts"}', 'seating/seatposts'),
+    ('*', '{Pedals}', 'pedals'),
+    ('*', '{Tools}', 'tools'),
+    ('JensonUSA', '{Components,Brakes}', 'brakes/disc-brakes')
+ON CONFLICT ON CONSTRAINT unique_category_mapping DO NOTHING;
@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS idx_part_specs_key_value;
+
+ALTER TABLE part_specs DROP COLUMN IF EXISTS unit;
+ALTER TABLE part_specs DROP COLUMN IF EXISTS numeric_value;
+ALTER TABLE part_specs DROP COLUMN IF EXISTS key;
@@ -0,0 +1,6 @@
+-- Canonical spec keys with numeric values in the attribute's unit
+ALTER TABLE part_specs ADD COLUMN IF NOT EXISTS key VARCHAR(100);
+ALTER TABLE part_specs ADD COLUMN IF NOT EXISTS numeric_value DOUBLE PRECISION;
+ALTER TABLE part_specs ADD COLUMN IF NOT EXISTS unit VARCHAR(20);
+
+CREATE INDEX IF NOT EXISTS idx_part_specs_key_value ON part_specs(key, numeric_value);
@@ -0,0 +1,19 @@
+-- Sizes, colours and other options of a part with their own price and stock
+CREATE TABLE IF NOT EXISTS part_variants (
+    id SERIAL PRIMARY KEY,
+    part_id VARCHAR(36) NOT NULL,
+    title VARCHAR(255) NOT NULL,
+    attributes JSONB NOT NULL DEFAULT '{}',
+    sku VARCHAR(100),
+    gtin VARCHAR(14),
+    price DECIMAL(10, 2) NOT NULL,
+    msrp DECIMAL(10, 2),
+    in_stock BOOLEAN NOT NULL DEFAULT FALSE,
+    url VARCHAR(2048),
+    image VARCHAR(2048),
+    position INTEGER NOT NULL DEFAULT 0,
+    FOREIGN KEY (part_id) REFERENCES parts(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_part_variants_part_id ON part_variants(part_id);
+CREATE INDEX IF NOT EXISTS idx_part_variants_gtin ON part_variants(gtin);
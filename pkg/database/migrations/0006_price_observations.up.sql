@@ -0,0 +1,19 @@
+-- Price and stock of each part, recorded whenever either changes
+CREATE TABLE IF NOT EXISTS price_observations (
+    id BIGSERIAL PRIMARY KEY,
+    part_id VARCHAR(36) NOT NULL,
+    price DECIMAL(10, 2) NOT NULL,
+    msrp DECIMAL(10, 2) NOT NULL DEFAULT 0,
+    currency VARCHAR(3) NOT NULL DEFAULT 'USD',
+    in_stock BOOLEAN NOT NULL DEFAULT FALSE,
+    observed_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    FOREIGN KEY (part_id) REFERENCES parts(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_price_observations_part_observed ON price_observations(part_id, observed_at);
+
+-- Start the history of existing parts at their current price
+INSERT INTO price_observations (part_id, price, msrp, currency, in_stock, observed_at)
+SELECT id, price, COALESCE(msrp, 0), currency, in_stock, updated_at
+FROM parts p
+WHERE NOT EXISTS (SELECT 1 FROM price_observations o WHERE o.part_id = p.id);
@@ -0,0 +1,34 @@
+-- Alert rules notifying webhooks of price drops and restocks
+CREATE TABLE IF NOT EXISTS alert_rules (
+    id VARCHAR(36) PRIMARY KEY,
+    part_id VARCHAR(36) NOT NULL,
+    type VARCHAR(20) NOT NULL CHECK (type IN ('price_below', 'price_drop', 'back_in_stock')),
+    threshold DECIMAL(10, 2),
+    drop_percent DECIMAL(5, 2),
+    baseline_price DECIMAL(10, 2),
+    webhook_url VARCHAR(2048) NOT NULL,
+    secret VARCHAR(128) NOT NULL,
+    triggered BOOLEAN NOT NULL DEFAULT FALSE,
+    last_triggered_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    FOREIGN KEY (part_id) REFERENCES parts(id) ON DELETE CASCADE
+);
+
+-- Webhook deliveries of fired alerts and the outcome of their latest attempt
+CREATE TABLE IF NOT EXISTS alert_deliveries (
+    id BIGSERIAL PRIMARY KEY,
+    alert_id VARCHAR(36) NOT NULL,
+    payload JSONB NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'pending',
+    attempts INTEGER NOT NULL DEFAULT 0,
+    last_status_code INTEGER,
+    last_error TEXT,
+    next_attempt_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    delivered_at TIMESTAMP WITH TIME ZONE,
+    FOREIGN KEY (alert_id) REFERENCES alert_rules(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_alert_rules_part_id ON alert_rules(part_id);
+CREATE INDEX IF NOT EXISTS idx_alert_deliveries_alert_id ON alert_deliveries(alert_id, created_at);
+CREATE INDEX IF NOT EXISTS idx_alert_deliveries_due ON alert_deliveries(next_attempt_at) WHERE status = 'pending';
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS search_terms;
+DROP INDEX IF EXISTS parts_trgm_idx;
+DROP EXTENSION IF EXISTS pg_trgm;
@@ -0,0 +1,17 @@
+-- Trigram similarity for typo-tolerant search
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+
+CREATE INDEX IF NOT EXISTS parts_trgm_idx ON parts USING GIN ((brand || ' ' || model) gin_trgm_ops);
+
+-- Words of brands and models, for "did you mean" suggestions
+CREATE TABLE IF NOT EXISTS search_terms (
+    term VARCHAR(100) PRIMARY KEY
+);
+
+CREATE INDEX IF NOT EXISTS idx_search_terms_trgm ON search_terms USING GIN (term gin_trgm_ops);
+
+INSERT INTO search_terms (term)
+SELECT DISTINCT word
+FROM parts, regexp_split_to_table(lower(brand || ' ' || model), '[^[:alnum:]]+') word
+WHERE length(word) BETWEEN 2 AND 100 AND word ~ '[[:alpha:]]'
+ON CONFLICT (term) DO NOTHING;
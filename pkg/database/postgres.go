@@ -2,11 +2,13 @@ package database
 
 import (
 	"context"
-	"database/sql"
+	"errors"
 	"fmt"
 	"os"
+	"sort"
 	"time"
 
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/sosadtsia/bike-parts-finder/pkg/models"
 )
@@ -66,107 +68,243 @@ func (c *PostgresClient) Ping() error {
 	return nil
 }
 
+// discountColumn is the discount of a part off its MSRP in percent
+const discountColumn = `(CASE WHEN msrp > price THEN (msrp - price) / msrp * 100 ELSE 0 END)`
+
+// partColumns are the parts columns read into a models.Part, in the order
+// of partFields
+const partColumns = `id, brand, model, category, sub_category, price, msrp, currency,
+		       in_stock, rating, num_reviews, description, url, source, created_at, updated_at,
+		       COALESCE(gtin, ''), COALESCE(mpn, ''), COALESCE(sku, ''),
+		       COALESCE(category_id, ''), breadcrumbs, ` + discountColumn + `::float8`
+
+// partFields returns the scan destinations for partColumns
+func partFields(part *models.Part) []any {
+	return []any{
+		&part.ID, &part.Brand, &part.Model, &part.Category, &part.SubCategory,
+		&part.Price, &part.MSRP, &part.Currency, &part.InStock, &part.Rating,
+		&part.NumReviews, &part.Description, &part.URL, &part.Source,
+		&part.CreatedAt, &part.UpdatedAt,
+		&part.GTIN, &part.MPN, &part.SKU,
+		&part.CategoryID, &part.Breadcrumbs, &part.Discount,
+	}
+}
+
+// storePartsChunkSize is the most parts StoreParts writes in one
+// transaction
+const storePartsChunkSize = 500
+
+// upsertPartQuery inserts a part or updates the stored part with its ID
+const upsertPartQuery = `
+	INSERT INTO parts (
+		id, brand, model, category, sub_category, price, msrp, currency,
+		in_stock, rating, num_reviews, description, url, source, created_at, updated_at,
+		gtin, mpn, sku, category_id, breadcrumbs
+	) VALUES (
+		$1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16,
+		NULLIF($17, ''), NULLIF($18, ''), NULLIF($19, ''), NULLIF($20, ''), $21
+	) ON CONFLICT (id) DO UPDATE SET
+		brand = $2,
+		model = $3,
+		category = $4,
+		sub_category = $5,
+		price = $6,
+		msrp = $7,
+		currency = $8,
+		in_stock = $9,
+		rating = $10,
+		num_reviews = $11,
+		description = $12,
+		url = $13,
+		source = $14,
+		updated_at = $16,
+		gtin = NULLIF($17, ''),
+		mpn = NULLIF($18, ''),
+		sku = NULLIF($19, ''),
+		category_id = NULLIF($20, ''),
+		breadcrumbs = $21`
+
 // StorePart stores a bike part in the database
 func (c *PostgresClient) StorePart(ctx context.Context, part models.Part) error {
-	query := `
-		INSERT INTO parts (
-			id, brand, model, category, sub_category, price, msrp, currency,
-			in_stock, rating, num_reviews, description, url, source, created_at, updated_at
-		) VALUES (
-			$1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16
-		) ON CONFLICT (id) DO UPDATE SET
-			brand = $2,
-			model = $3,
-			category = $4,
-			sub_category = $5,
-			price = $6,
-			msrp = $7,
-			currency = $8,
-			in_stock = $9,
-			rating = $10,
-			num_reviews = $11,
-			description = $12,
-			url = $13,
-			source = $14,
-			updated_at = $16
-		RETURNING id`
-
-	// If part doesn't have created_at or updated_at timestamps, set them to now
-	now := time.Now()
-	if part.CreatedAt.IsZero() {
-		part.CreatedAt = now
+	return c.StoreParts(ctx, []models.Part{part})
+}
+
+// StoreParts stores bike parts in the database. Parts are written in
+// transactions of up to storePartsChunkSize parts, so a part is never left
+// with only some of its specs, images or variants; if a chunk fails, the
+// chunks before it stay stored. The specs, images and variants of the
+// parts are replaced, so those no longer listed are deleted.
+func (c *PostgresClient) StoreParts(ctx context.Context, parts []models.Part) error {
+	parts = latestParts(parts)
+	for start := 0; start < len(parts); start += storePartsChunkSize {
+		end := min(start+storePartsChunkSize, len(parts))
+		if err := c.storePartsChunk(ctx, parts[start:end]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// latestParts drops all but the last of parts with the same ID, which would
+// otherwise have their specs stored twice
+func latestParts(parts []models.Part) []models.Part {
+	last := make(map[string]int, len(parts))
+	for i, part := range parts {
+		last[part.ID] = i
 	}
-	if part.UpdatedAt.IsZero() {
-		part.UpdatedAt = now
+	if len(last) == len(parts) {
+		return parts
 	}
 
-	_, err := c.pool.Exec(ctx, query,
-		part.ID, part.Brand, part.Model, part.Category, part.SubCategory,
-		part.Price, part.MSRP, part.Currency, part.InStock, part.Rating,
-		part.NumReviews, part.Description, part.URL, part.Source,
-		part.CreatedAt, part.UpdatedAt,
-	)
+	latest := make([]models.Part, 0, len(last))
+	for i, part := range parts {
+		if last[part.ID] == i {
+			latest = append(latest, part)
+		}
+	}
+	return latest
+}
+
+// storePartsChunk stores parts in one transaction: the parts are upserted in
+// a batch, then their specs, images and variants are replaced with COPY
+func (c *PostgresClient) storePartsChunk(ctx context.Context, parts []models.Part) error {
+	tx, err := c.pool.Begin(ctx)
 	if err != nil {
-		return fmt.Errorf("storing part %s: %w", part.ID, err)
+		return fmt.Errorf("starting transaction: %w", err)
 	}
+	defer tx.Rollback(ctx)
 
-	// Store specs
-	if len(part.Specs) > 0 {
-		err = c.storeSpecs(ctx, part.ID, part.Specs)
-		if err != nil {
-			return fmt.Errorf("storing specs for part %s: %w", part.ID, err)
+	// If a part doesn't have created_at or updated_at timestamps, set them to now
+	now := time.Now()
+	batch := &pgx.Batch{}
+	var ids []string
+	var specRows, imageRows, variantRows [][]any
+	for _, part := range parts {
+		if part.CreatedAt.IsZero() {
+			part.CreatedAt = now
+		}
+		if part.UpdatedAt.IsZero() {
+			part.UpdatedAt = now
+		}
+		batch.Queue(upsertPartQuery,
+			part.ID, part.Brand, part.Model, part.Category, part.SubCategory,
+			part.Price, part.MSRP, part.Currency, part.InStock, part.Rating,
+			part.NumReviews, part.Description, part.URL, part.Source,
+			part.CreatedAt, part.UpdatedAt,
+			part.GTIN, part.MPN, part.SKU, part.CategoryID, part.Breadcrumbs,
+		)
+		ids = append(ids, part.ID)
+
+		for _, spec := range uniqueSpecs(part.Specs) {
+			specRows = append(specRows, []any{
+				part.ID, spec.Name, spec.Value, nullIfEmpty(spec.Key), spec.NumericValue, nullIfEmpty(spec.Unit),
+			})
+		}
+
+		for i, url := range part.Images {
+			imageRows = append(imageRows, []any{part.ID, url, i})
+		}
+
+		for i, variant := range part.Variants {
+			attributes := variant.Attributes
+			if attributes == nil {
+				attributes = map[string]string{}
+			}
+			variantRows = append(variantRows, []any{
+				part.ID, variant.Title, attributes, nullIfEmpty(variant.SKU), nullIfEmpty(variant.GTIN),
+				variant.Price, variant.MSRP, variant.InStock, nullIfEmpty(variant.URL), nullIfEmpty(variant.Image), i,
+			})
 		}
 	}
 
-	// Store images
-	if len(part.Images) > 0 {
-		err = c.storeImages(ctx, part.ID, part.Images)
-		if err != nil {
-			return fmt.Errorf("storing images for part %s: %w", part.ID, err)
+	// Upsert the parts
+	results := tx.SendBatch(ctx, batch)
+	for _, part := range parts {
+		if _, err := results.Exec(); err != nil {
+			results.Close()
+			return fmt.Errorf("storing part %s: %w", part.ID, err)
 		}
 	}
+	if err := results.Close(); err != nil {
+		return fmt.Errorf("storing parts: %w", err)
+	}
 
-	return nil
-}
+	// Replace specs, images and variants
+	if err := replaceRows(ctx, tx, "part_specs", ids, specRows,
+		"part_id", "name", "value", "key", "numeric_value", "unit"); err != nil {
+		return fmt.Errorf("storing specs: %w", err)
+	}
+	if err := replaceRows(ctx, tx, "part_images", ids, imageRows,
+		"part_id", "url", "position"); err != nil {
+		return fmt.Errorf("storing images: %w", err)
+	}
+	if err := replaceRows(ctx, tx, "part_variants", ids, variantRows,
+		"part_id", "title", "attributes", "sku", "gtin", "price", "msrp", "in_stock", "url", "image", "position"); err != nil {
+		return fmt.Errorf("storing variants: %w", err)
+	}
 
-// storeSpecs stores specifications for a part
-func (c *PostgresClient) storeSpecs(ctx context.Context, partID string, specs []models.Spec) error {
-	// First, delete existing specs
-	_, err := c.pool.Exec(ctx, "DELETE FROM part_specs WHERE part_id = $1", partID)
+	// Record the brand and model words for search suggestions, in order so
+	// that concurrent chunks don't deadlock
+	termSet := make(map[string]bool)
+	for _, part := range parts {
+		for _, term := range searchTerms(part) {
+			termSet[term] = true
+		}
+	}
+	terms := make([]string, 0, len(termSet))
+	for term := range termSet {
+		terms = append(terms, term)
+	}
+	sort.Strings(terms)
+	_, err = tx.Exec(ctx, `
+		INSERT INTO search_terms (term) SELECT unnest($1::text[]) ON CONFLICT (term) DO NOTHING
+	`, terms)
 	if err != nil {
-		return err
+		return fmt.Errorf("storing search terms: %w", err)
 	}
 
-	// Then insert new specs
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("committing parts: %w", err)
+	}
+	return nil
+}
+
+// uniqueSpecs drops all but the first of specs with the same name, which
+// retailers sometimes list twice but a part can only have once
+func uniqueSpecs(specs []models.Spec) []models.Spec {
+	seen := make(map[string]bool, len(specs))
+	unique := make([]models.Spec, 0, len(specs))
 	for _, spec := range specs {
-		_, err = c.pool.Exec(ctx, "INSERT INTO part_specs (part_id, name, value) VALUES ($1, $2, $3)",
-			partID, spec.Name, spec.Value)
-		if err != nil {
-			return err
+		if !seen[spec.Name] {
+			seen[spec.Name] = true
+			unique = append(unique, spec)
 		}
 	}
-
-	return nil
+	return unique
 }
 
-// storeImages stores images for a part
-func (c *PostgresClient) storeImages(ctx context.Context, partID string, images []string) error {
-	// First, delete existing images
-	_, err := c.pool.Exec(ctx, "DELETE FROM part_images WHERE part_id = $1", partID)
-	if err != nil {
+// replaceRows deletes the rows of a table belonging to the given parts and
+// copies in new rows
+func replaceRows(ctx context.Context, tx pgx.Tx, table string, partIDs []string, rows [][]any, columns ...string) error {
+	if len(partIDs) == 0 {
+		return nil
+	}
+	if _, err := tx.Exec(ctx, "DELETE FROM "+table+" WHERE part_id = ANY($1)", partIDs); err != nil {
 		return err
 	}
-
-	// Then insert new images
-	for i, url := range images {
-		_, err = c.pool.Exec(ctx, "INSERT INTO part_images (part_id, url, position) VALUES ($1, $2, $3)",
-			partID, url, i)
-		if err != nil {
-			return err
-		}
+	if len(rows) == 0 {
+		return nil
 	}
+	_, err := tx.CopyFrom(ctx, pgx.Identifier{table}, columns, pgx.CopyFromRows(rows))
+	return err
+}
 
-	return nil
+// nullIfEmpty returns nil for an empty string, which is stored as NULL
+func nullIfEmpty(s string) any {
+	if s == "" {
+		return nil
+	}
+	return s
 }
 
 // GetPartByID retrieves a part by ID
@@ -175,18 +313,12 @@ func (c *PostgresClient) GetPartByID(ctx context.Context, id string) (models.Par
 
 	// Query the part
 	err := c.pool.QueryRow(ctx, `
-		SELECT id, brand, model, category, sub_category, price, msrp, currency,
-		       in_stock, rating, num_reviews, description, url, source, created_at, updated_at
+		SELECT `+partColumns+`
 		FROM parts WHERE id = $1
-	`, id).Scan(
-		&part.ID, &part.Brand, &part.Model, &part.Category, &part.SubCategory,
-		&part.Price, &part.MSRP, &part.Currency, &part.InStock, &part.Rating,
-		&part.NumReviews, &part.Description, &part.URL, &part.Source,
-		&part.CreatedAt, &part.UpdatedAt,
-	)
+	`, id).Scan(partFields(&part)...)
 	if err != nil {
-		if err == sql.ErrNoRows {
-			return part, fmt.Errorf("part not found")
+		if errors.Is(err, pgx.ErrNoRows) {
+			return part, ErrPartNotFound
 		}
 		return part, err
 	}
@@ -205,12 +337,49 @@ func (c *PostgresClient) GetPartByID(ctx context.Context, id string) (models.Par
 	}
 	part.Images = images
 
+	// Load variants
+	variants, err := c.getPartVariants(ctx, id)
+	if err != nil {
+		return part, err
+	}
+	part.Variants = variants
+
 	return part, nil
 }
 
+// getPartVariants retrieves variants for a part
+func (c *PostgresClient) getPartVariants(ctx context.Context, partID string) ([]models.Variant, error) {
+	rows, err := c.pool.Query(ctx, `
+		SELECT title, attributes, COALESCE(sku, ''), COALESCE(gtin, ''), price, COALESCE(msrp, 0),
+		       in_stock, COALESCE(url, ''), COALESCE(image, '')
+		FROM part_variants WHERE part_id = $1 ORDER BY position
+	`, partID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var variants []models.Variant
+	for rows.Next() {
+		var variant models.Variant
+		if err := rows.Scan(
+			&variant.Title, &variant.Attributes, &variant.SKU, &variant.GTIN, &variant.Price, &variant.MSRP,
+			&variant.InStock, &variant.URL, &variant.Image,
+		); err != nil {
+			return nil, err
+		}
+		variants = append(variants, variant)
+	}
+
+	return variants, rows.Err()
+}
+
 // getPartSpecs retrieves specs for a part
 func (c *PostgresClient) getPartSpecs(ctx context.Context, partID string) ([]models.Spec, error) {
-	rows, err := c.pool.Query(ctx, "SELECT name, value FROM part_specs WHERE part_id = $1 ORDER BY name", partID)
+	rows, err := c.pool.Query(ctx, `
+		SELECT name, value, COALESCE(key, ''), numeric_value, COALESCE(unit, '')
+		FROM part_specs WHERE part_id = $1 ORDER BY name
+	`, partID)
 	if err != nil {
 		return nil, err
 	}
@@ -219,7 +388,7 @@ func (c *PostgresClient) getPartSpecs(ctx context.Context, partID string) ([]mod
 	var specs []models.Spec
 	for rows.Next() {
 		var spec models.Spec
-		if err := rows.Scan(&spec.Name, &spec.Value); err != nil {
+		if err := rows.Scan(&spec.Name, &spec.Value, &spec.Key, &spec.NumericValue, &spec.Unit); err != nil {
 			return nil, err
 		}
 		specs = append(specs, spec)
@@ -248,86 +417,79 @@ func (c *PostgresClient) getPartImages(ctx context.Context, partID string) ([]st
 	return images, rows.Err()
 }
 
-// GetParts retrieves a list of parts with pagination
-func (c *PostgresClient) GetParts(ctx context.Context, offset, limit int) ([]models.Part, error) {
+// loadPartDetails loads the specs and images of a page of parts with one
+// query each instead of two per part
+func (c *PostgresClient) loadPartDetails(ctx context.Context, parts []models.Part) error {
+	if len(parts) == 0 {
+		return nil
+	}
+
+	ids := make([]string, len(parts))
+	byID := make(map[string]int, len(parts))
+	for i, part := range parts {
+		ids[i] = part.ID
+		byID[part.ID] = i
+	}
+
+	// Load specs
 	rows, err := c.pool.Query(ctx, `
-		SELECT id, brand, model, category, sub_category, price, msrp, currency,
-		       in_stock, rating, num_reviews, description, url, source, created_at, updated_at
-		FROM parts ORDER BY created_at DESC LIMIT $1 OFFSET $2
-	`, limit, offset)
+		SELECT part_id, name, value, COALESCE(key, ''), numeric_value, COALESCE(unit, '')
+		FROM part_specs WHERE part_id = ANY($1) ORDER BY part_id, name
+	`, ids)
 	if err != nil {
-		return nil, err
+		return fmt.Errorf("loading part specs: %w", err)
 	}
-	defer rows.Close()
-
-	var parts []models.Part
 	for rows.Next() {
-		var part models.Part
-		if err := rows.Scan(
-			&part.ID, &part.Brand, &part.Model, &part.Category, &part.SubCategory,
-			&part.Price, &part.MSRP, &part.Currency, &part.InStock, &part.Rating,
-			&part.NumReviews, &part.Description, &part.URL, &part.Source,
-			&part.CreatedAt, &part.UpdatedAt,
-		); err != nil {
-			return nil, err
+		var partID string
+		var spec models.Spec
+		if err := rows.Scan(&partID, &spec.Name, &spec.Value, &spec.Key, &spec.NumericValue, &spec.Unit); err != nil {
+			rows.Close()
+			return fmt.Errorf("loading part specs: %w", err)
 		}
-		parts = append(parts, part)
+		i := byID[partID]
+		parts[i].Specs = append(parts[i].Specs, spec)
 	}
-
+	rows.Close()
 	if err := rows.Err(); err != nil {
-		return nil, err
+		return fmt.Errorf("loading part specs: %w", err)
 	}
 
-	// Load specs and images for each part
-	for i, part := range parts {
-		specs, err := c.getPartSpecs(ctx, part.ID)
-		if err != nil {
-			return nil, err
-		}
-		parts[i].Specs = specs
-
-		images, err := c.getPartImages(ctx, part.ID)
-		if err != nil {
-			return nil, err
-		}
-		parts[i].Images = images
+	// Load images
+	rows, err = c.pool.Query(ctx, `
+		SELECT part_id, url FROM part_images WHERE part_id = ANY($1) ORDER BY part_id, position
+	`, ids)
+	if err != nil {
+		return fmt.Errorf("loading part images: %w", err)
 	}
-
-	return parts, nil
-}
-
-// SearchParts searches for parts based on query parameters
-func (c *PostgresClient) SearchParts(ctx context.Context, query, brand, category string, offset, limit int) ([]models.Part, error) {
-	// Build the query
-	sqlQuery := `
-		SELECT id, brand, model, category, sub_category, price, msrp, currency,
-		       in_stock, rating, num_reviews, description, url, source, created_at, updated_at
-		FROM parts WHERE 1=1
-	`
-	args := []interface{}{}
-
-	// Add filters
-	if query != "" {
-		sqlQuery += " AND (brand ILIKE $" + fmt.Sprintf("%d", len(args)+1) + " OR model ILIKE $" + fmt.Sprintf("%d", len(args)+1) + " OR description ILIKE $" + fmt.Sprintf("%d", len(args)+1) + ")"
-		args = append(args, "%"+query+"%")
+	defer rows.Close()
+	for rows.Next() {
+		var partID, url string
+		if err := rows.Scan(&partID, &url); err != nil {
+			return fmt.Errorf("loading part images: %w", err)
+		}
+		i := byID[partID]
+		parts[i].Images = append(parts[i].Images, url)
 	}
-
-	if brand != "" {
-		sqlQuery += " AND brand ILIKE $" + fmt.Sprintf("%d", len(args)+1)
-		args = append(args, "%"+brand+"%")
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("loading part images: %w", err)
 	}
 
-	if category != "" {
-		sqlQuery += " AND category ILIKE $" + fmt.Sprintf("%d", len(args)+1)
-		args = append(args, "%"+category+"%")
-	}
+	return nil
+}
 
-	// Add pagination
-	sqlQuery += " ORDER BY created_at DESC LIMIT $" + fmt.Sprintf("%d", len(args)+1) + " OFFSET $" + fmt.Sprintf("%d", len(args)+2)
-	args = append(args, limit, offset)
+// GetParts retrieves a list of parts with pagination, newest first
+func (c *PostgresClient) GetParts(ctx context.Context, offset, limit int) ([]models.Part, error) {
+	result, err := c.SearchParts(ctx, SearchOptions{Offset: offset, Limit: limit})
+	return result.Parts, err
+}
 
-	// Execute the query
-	rows, err := c.pool.Query(ctx, sqlQuery, args...)
+// GetPartsByGTIN retrieves the parts of all retailers that sell the product
+// with the given normalized GTIN
+func (c *PostgresClient) GetPartsByGTIN(ctx context.Context, gtin string) ([]models.Part, error) {
+	rows, err := c.pool.Query(ctx, `
+		SELECT `+partColumns+`
+		FROM parts WHERE gtin = $1 ORDER BY price
+	`, gtin)
 	if err != nil {
 		return nil, err
 	}
@@ -336,12 +498,7 @@ func (c *PostgresClient) SearchParts(ctx context.Context, query, brand, category
 	var parts []models.Part
 	for rows.Next() {
 		var part models.Part
-		if err := rows.Scan(
-			&part.ID, &part.Brand, &part.Model, &part.Category, &part.SubCategory,
-			&part.Price, &part.MSRP, &part.Currency, &part.InStock, &part.Rating,
-			&part.NumReviews, &part.Description, &part.URL, &part.Source,
-			&part.CreatedAt, &part.UpdatedAt,
-		); err != nil {
+		if err := rows.Scan(partFields(&part)...); err != nil {
 			return nil, err
 		}
 		parts = append(parts, part)
@@ -351,19 +508,9 @@ func (c *PostgresClient) SearchParts(ctx context.Context, query, brand, category
 		return nil, err
 	}
 
-	// Load specs and images for each part
-	for i, part := range parts {
-		specs, err := c.getPartSpecs(ctx, part.ID)
-		if err != nil {
-			return nil, err
-		}
-		parts[i].Specs = specs
-
-		images, err := c.getPartImages(ctx, part.ID)
-		if err != nil {
-			return nil, err
-		}
-		parts[i].Images = images
+	// Load specs and images for all parts at once
+	if err := c.loadPartDetails(ctx, parts); err != nil {
+		return nil, err
 	}
 
 	return parts, nil
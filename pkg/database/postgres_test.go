@@ -0,0 +1,177 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"testing"
+
+	"github.com/sosadtsia/bike-parts-finder/pkg/models"
+)
+
+// testSource is the source of the parts stored by tests and benchmarks,
+// which are deleted when they finish
+const testSource = "benchmark"
+
+// newTestClient connects to the migrated database named by
+// TEST_DATABASE_URL, skipping the test when it is not set. Tests write to
+// the database, so they never run against DATABASE_URL.
+func newTestClient(tb testing.TB) *PostgresClient {
+	tb.Helper()
+	dsn := os.Getenv("TEST_DATABASE_URL")
+	if dsn == "" {
+		tb.Skip("TEST_DATABASE_URL is not set")
+	}
+	tb.Setenv("DATABASE_URL", dsn)
+
+	c, err := NewPostgresClient()
+	if err != nil {
+		tb.Fatal(err)
+	}
+	tb.Cleanup(c.Close)
+	if err := c.Migrate(context.Background()); err != nil {
+		tb.Fatal(err)
+	}
+	tb.Cleanup(func() {
+		if _, err := c.pool.Exec(context.Background(), "DELETE FROM parts WHERE source = $1", testSource); err != nil {
+			tb.Errorf("deleting test parts: %v", err)
+		}
+	})
+	return c
+}
+
+// benchmarkParts returns n parts with specs, images and variants like
+// those of a scraped product page
+func benchmarkParts(n int) []models.Part {
+	parts := make([]models.Part, n)
+	for i := range parts {
+		weight := float64(200 + i%100)
+		parts[i] = models.Part{
+			ID:          fmt.Sprintf("benchmark-%d", i),
+			Brand:       "Shimano",
+			Model:       fmt.Sprintf("Deore XT M8100 Cassette %d", i),
+			Category:    "Drivetrain",
+			SubCategory: "Cassettes",
+			Price:       99.99 + float64(i%50),
+			MSRP:        149.99,
+			Currency:    "USD",
+			InStock:     i%3 != 0,
+			Description: "12-speed cassette with a 10-51t range for wide gearing on the trail",
+			URL:         fmt.Sprintf("https://example.com/products/%d", i),
+			Source:      testSource,
+			Images:      []string{fmt.Sprintf("https://example.com/%d-1.jpg", i), fmt.Sprintf("https://example.com/%d-2.jpg", i)},
+			Specs: []models.Spec{
+				{Name: "Speed", Value: "12", Key: "speeds", NumericValue: ptr(12.0)},
+				{Name: "Weight", Value: fmt.Sprintf("%gg", weight), Key: "weight", NumericValue: &weight, Unit: "g"},
+				{Name: "Range", Value: "10-51t"},
+				{Name: "Material", Value: "Steel, aluminium"},
+			},
+			Variants: []models.Variant{
+				{Title: "10-45t", Attributes: map[string]string{"Range": "10-45t"}, Price: 99.99, InStock: true},
+				{Title: "10-51t", Attributes: map[string]string{"Range": "10-51t"}, Price: 109.99},
+			},
+		}
+	}
+	return parts
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
+
+// BenchmarkLoadPartDetails compares loading the specs and images of a
+// 50-part page in one batch with loading them part by part
+func BenchmarkLoadPartDetails(b *testing.B) {
+	c := newTestClient(b)
+	ctx := context.Background()
+	if err := c.StoreParts(ctx, benchmarkParts(50)); err != nil {
+		b.Fatal(err)
+	}
+	result, err := c.SearchParts(ctx, SearchOptions{Source: testSource, Limit: 50, Summary: true})
+	if err != nil {
+		b.Fatal(err)
+	}
+	page := result.Parts
+
+	b.Run("batched", func(b *testing.B) {
+		for b.Loop() {
+			if err := c.loadPartDetails(ctx, page); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("per_part", func(b *testing.B) {
+		for b.Loop() {
+			for i := range page {
+				if err := c.loadPartDetails(ctx, page[i:i+1]); err != nil {
+					b.Fatal(err)
+				}
+			}
+		}
+	})
+}
+
+// BenchmarkSearchParts measures a 50-part search page with and without
+// specs and images
+func BenchmarkSearchParts(b *testing.B) {
+	c := newTestClient(b)
+	ctx := context.Background()
+	if err := c.StoreParts(ctx, benchmarkParts(1000)); err != nil {
+		b.Fatal(err)
+	}
+
+	for _, summary := range []bool{false, true} {
+		b.Run(fmt.Sprintf("summary=%t", summary), func(b *testing.B) {
+			opts := SearchOptions{Query: "cassette", Source: testSource, Limit: 50, Summary: summary}
+			for b.Loop() {
+				if _, err := c.SearchParts(ctx, opts); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+// BenchmarkStoreParts compares storing 500 parts in one batch with storing
+// them one at a time
+func BenchmarkStoreParts(b *testing.B) {
+	c := newTestClient(b)
+	ctx := context.Background()
+	parts := benchmarkParts(500)
+
+	b.Run("batched", func(b *testing.B) {
+		for b.Loop() {
+			if err := c.StoreParts(ctx, parts); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("per_part", func(b *testing.B) {
+		for b.Loop() {
+			for _, part := range parts {
+				if err := c.StorePart(ctx, part); err != nil {
+					b.Fatal(err)
+				}
+			}
+		}
+	})
+}
+
+func TestUniqueSpecs(t *testing.T) {
+	specs := uniqueSpecs([]models.Spec{
+		{Name: "Weight", Value: "380g"},
+		{Name: "Speed", Value: "12"},
+		{Name: "Weight", Value: "0.38kg"},
+		{Name: "weight", Value: "380 g"},
+	})
+
+	want := []string{"Weight=380g", "Speed=12", "weight=380 g"}
+	if len(specs) != len(want) {
+		t.Fatalf("got %+v, want %v", specs, want)
+	}
+	for i, spec := range specs {
+		if got := spec.Name + "=" + spec.Value; got != want[i] {
+			t.Errorf("spec %d is %s, want %s", i, got, want[i])
+		}
+	}
+}
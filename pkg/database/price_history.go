@@ -0,0 +1,137 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/sosadtsia/bike-parts-finder/pkg/models"
+)
+
+// recordPriceObservationQuery records the price and stock of a part unless
+// they are unchanged since its last observation. A part without a price,
+// whose price could not be parsed, keeps the price of its last observation
+// so that a change in stock is still recorded; without one nothing is
+// recorded.
+const recordPriceObservationQuery = `
+	WITH last AS (
+		SELECT price, msrp, currency, in_stock FROM price_observations
+		WHERE part_id = $1 ORDER BY observed_at DESC LIMIT 1
+	), observed AS (
+		SELECT round($2::numeric, 2) AS price, round($3::numeric, 2) AS msrp, $4::text AS currency
+		WHERE round($2::numeric, 2) > 0
+		UNION ALL
+		SELECT price, msrp, currency FROM last
+		WHERE round($2::numeric, 2) <= 0
+	)
+	INSERT INTO price_observations (part_id, price, msrp, currency, in_stock)
+	SELECT $1, o.price, o.msrp, o.currency, $5::boolean
+	FROM observed o
+	WHERE NOT EXISTS (
+		SELECT 1 FROM last
+		WHERE last.price = o.price AND last.msrp = o.msrp
+		  AND last.currency = o.currency AND last.in_stock = $5::boolean
+	)`
+
+// RecordPriceObservation records the price and stock of a part unless they
+// are unchanged since its last observation. A part without a price keeps
+// the last observed price. It reports whether an observation was recorded.
+func (c *PostgresClient) RecordPriceObservation(ctx context.Context, part models.Part) (bool, error) {
+	n, err := c.RecordPriceObservations(ctx, []models.Part{part})
+	return n > 0, err
+}
+
+// RecordPriceObservations records the prices and stock of parts that
+// changed since their last observations in one round trip. It returns how
+// many observations were recorded.
+func (c *PostgresClient) RecordPriceObservations(ctx context.Context, parts []models.Part) (int, error) {
+	if len(parts) == 0 {
+		return 0, nil
+	}
+
+	batch := &pgx.Batch{}
+	for _, part := range parts {
+		batch.Queue(recordPriceObservationQuery, part.ID, part.Price, part.MSRP, part.Currency, part.InStock)
+	}
+
+	results := c.pool.SendBatch(ctx, batch)
+	defer results.Close()
+
+	recorded := 0
+	for _, part := range parts {
+		tag, err := results.Exec()
+		if err != nil {
+			return recorded, fmt.Errorf("recording price of part %s: %w", part.ID, err)
+		}
+		recorded += int(tag.RowsAffected())
+	}
+	return recorded, results.Close()
+}
+
+// GetPriceObservations retrieves the observations of a part since a time in
+// chronological order, starting with the last observation before it
+func (c *PostgresClient) GetPriceObservations(ctx context.Context, partID string, since time.Time) ([]models.PriceObservation, error) {
+	rows, err := c.pool.Query(ctx, `
+		SELECT price, msrp, currency, in_stock, observed_at FROM (
+			(SELECT * FROM price_observations
+			 WHERE part_id = $1 AND observed_at < $2
+			 ORDER BY observed_at DESC LIMIT 1)
+			UNION ALL
+			SELECT * FROM price_observations
+			WHERE part_id = $1 AND observed_at >= $2
+		) o
+		ORDER BY observed_at
+	`, partID, since)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var observations []models.PriceObservation
+	for rows.Next() {
+		var o models.PriceObservation
+		if err := rows.Scan(&o.Price, &o.MSRP, &o.Currency, &o.InStock, &o.ObservedAt); err != nil {
+			return nil, err
+		}
+		observations = append(observations, o)
+	}
+
+	return observations, rows.Err()
+}
+
+// PrunePriceObservations downsamples observations older than
+// downsampleBefore to the last observation of each part per day and deletes
+// those older than deleteBefore, keeping the one in effect at deleteBefore.
+// It returns the number of observations removed.
+func (c *PostgresClient) PrunePriceObservations(ctx context.Context, downsampleBefore, deleteBefore time.Time) (int64, error) {
+	downsampled, err := c.pool.Exec(ctx, `
+		DELETE FROM price_observations o
+		WHERE o.observed_at < $1
+		  AND EXISTS (
+			SELECT 1 FROM price_observations n
+			WHERE n.part_id = o.part_id
+			  AND n.observed_at > o.observed_at
+			  AND date_trunc('day', n.observed_at) = date_trunc('day', o.observed_at)
+		  )
+	`, downsampleBefore)
+	if err != nil {
+		return 0, err
+	}
+
+	deleted, err := c.pool.Exec(ctx, `
+		DELETE FROM price_observations o
+		WHERE o.observed_at < $1
+		  AND EXISTS (
+			SELECT 1 FROM price_observations n
+			WHERE n.part_id = o.part_id
+			  AND n.observed_at > o.observed_at
+			  AND n.observed_at <= $1
+		  )
+	`, deleteBefore)
+	if err != nil {
+		return downsampled.RowsAffected(), err
+	}
+
+	return downsampled.RowsAffected() + deleted.RowsAffected(), nil
+}
@@ -0,0 +1,78 @@
+package database
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/sosadtsia/bike-parts-finder/pkg/models"
+)
+
+// testRecordPriceObservations records a part's prices and stock through
+// repo and checks which observations are kept
+func testRecordPriceObservations(t *testing.T, repo PartRepository) {
+	ctx := context.Background()
+	part := models.Part{
+		ID: "price-history-test", Brand: "Shimano", Model: "XT Cassette", Category: "Drivetrain",
+		Currency: "USD", URL: "https://example.com/products/xt-cassette", Source: testSource,
+	}
+	if err := repo.StorePart(ctx, part); err != nil {
+		t.Fatal(err)
+	}
+	since := time.Now().Add(-time.Minute)
+
+	steps := []struct {
+		price   float64
+		inStock bool
+		want    bool
+	}{
+		// Nothing is recorded without a price to carry forward
+		{0, true, false},
+		{99.99, true, true},
+		{99.99, true, false},
+		{89.99, true, true},
+		// An unparsed price keeps the last price and records the stock
+		{0, false, true},
+		{0, false, false},
+		{89.99, false, false},
+		{89.99, true, true},
+	}
+	for i, step := range steps {
+		part.Price, part.MSRP, part.InStock = step.price, 0, step.inStock
+		recorded, err := repo.RecordPriceObservation(ctx, part)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if recorded != step.want {
+			t.Errorf("step %d: recorded %v, want %v", i, recorded, step.want)
+		}
+	}
+
+	observations, err := repo.GetPriceObservations(ctx, part.ID, since)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []models.PriceObservation{
+		{Price: 99.99, InStock: true},
+		{Price: 89.99, InStock: true},
+		{Price: 89.99, InStock: false},
+		{Price: 89.99, InStock: true},
+	}
+	if len(observations) != len(want) {
+		t.Fatalf("got %+v, want %+v", observations, want)
+	}
+	for i, o := range observations {
+		if o.Price != want[i].Price || o.InStock != want[i].InStock || o.Currency != "USD" {
+			t.Errorf("observation %d is %+v, want %+v", i, o, want[i])
+		}
+	}
+}
+
+func TestRecordPriceObservations(t *testing.T) {
+	t.Run("memory", func(t *testing.T) {
+		testRecordPriceObservations(t, NewMemoryPartRepository())
+	})
+	t.Run("postgres", func(t *testing.T) {
+		testRecordPriceObservations(t, newTestClient(t))
+	})
+}
@@ -0,0 +1,52 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/sosadtsia/bike-parts-finder/pkg/models"
+)
+
+// ErrPartNotFound is returned when no part has the requested ID
+var ErrPartNotFound = errors.New("part not found")
+
+// ErrAlertNotFound is returned when no alert rule has the requested ID
+var ErrAlertNotFound = errors.New("alert not found")
+
+// PartRepository stores parts and their price history
+type PartRepository interface {
+	StorePart(ctx context.Context, part models.Part) error
+	StoreParts(ctx context.Context, parts []models.Part) error
+	GetPartByID(ctx context.Context, id string) (models.Part, error)
+	GetParts(ctx context.Context, offset, limit int) ([]models.Part, error)
+	GetPartsByGTIN(ctx context.Context, gtin string) ([]models.Part, error)
+	SearchParts(ctx context.Context, opts SearchOptions) (SearchResult, error)
+	HasSpecKey(ctx context.Context, key string) (bool, error)
+	RecordPriceObservation(ctx context.Context, part models.Part) (bool, error)
+	RecordPriceObservations(ctx context.Context, parts []models.Part) (int, error)
+	GetPriceObservations(ctx context.Context, partID string, since time.Time) ([]models.PriceObservation, error)
+}
+
+// AlertRepository stores alert rules and their webhook delivery logs
+type AlertRepository interface {
+	CreateAlertRule(ctx context.Context, rule models.AlertRule) (models.AlertRule, error)
+	GetAlertRule(ctx context.Context, id string) (models.AlertRule, error)
+	GetAlertRules(ctx context.Context, partID string) ([]models.AlertRule, error)
+	DeleteAlertRule(ctx context.Context, id string) (bool, error)
+	GetAlertDeliveries(ctx context.Context, alertID string, limit int) ([]models.AlertDelivery, error)
+}
+
+// CategoryRepository stores the category taxonomy, the rules mapping
+// retailer breadcrumbs to it and the breadcrumbs no rule matched
+type CategoryRepository interface {
+	GetCategories(ctx context.Context) ([]models.Category, error)
+	GetCategoryMappings(ctx context.Context) ([]models.CategoryMapping, error)
+	GetUnmappedCategories(ctx context.Context) ([]models.UnmappedCategory, error)
+}
+
+var (
+	_ PartRepository     = (*PostgresClient)(nil)
+	_ AlertRepository    = (*PostgresClient)(nil)
+	_ CategoryRepository = (*PostgresClient)(nil)
+)
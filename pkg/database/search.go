@@ -0,0 +1,611 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/sosadtsia/bike-parts-finder/pkg/models"
+)
+
+// Search orders
+const (
+	// SortRelevance orders by how well parts match the query, best first
+	SortRelevance = "relevance"
+	// SortFreshness orders by when parts were added, newest first
+	SortFreshness = "freshness"
+	// SortPrice orders by price, cheapest first
+	SortPrice = "price"
+	// SortDiscount orders by the discount off MSRP, biggest first
+	SortDiscount = "discount"
+	// SortRating orders by rating, best first, then by number of reviews,
+	// most first
+	SortRating = "rating"
+)
+
+// partDocument is the text search document of a part. It must match the
+// expression of the parts_search_idx index for searches to use it.
+const partDocument = `to_tsvector('english', brand || ' ' || model || ' ' || COALESCE(description, ''))`
+
+// snippetOptions configures the highlighted description snippets of search
+// results
+const snippetOptions = `StartSel="<mark>", StopSel="</mark>", MaxFragments=2, MaxWords=25, MinWords=8`
+
+// SpecFilter restricts a search to parts whose spec with the canonical Key
+// has a numeric value within Min and Max; nil bounds are open. A non-empty
+// Value instead requires the spec's text to be Value, ignoring case.
+type SpecFilter struct {
+	Key   string
+	Min   *float64
+	Max   *float64
+	Value string
+}
+
+// SearchOptions holds the filters, order and page of a part search
+type SearchOptions struct {
+	// Query is a web search style query: words are all required, "quoted
+	// phrases" match in order, -word excludes and "or" joins alternatives.
+	// The last word of a plain query also matches as a prefix.
+	Query       string
+	Brand       string
+	Category    string
+	SubCategory string
+	// Source is the retailer, matched ignoring case
+	Source  string
+	InStock *bool
+	// MinPrice and MaxPrice bound the price; nil bounds are open
+	MinPrice *float64
+	MaxPrice *float64
+	// MinDiscount is the least discount off MSRP in percent
+	MinDiscount *float64
+	MinRating   *float64
+	Specs       []SpecFilter
+	// Sort is one of the Sort constants. Left empty, results are ordered by
+	// relevance when there is a query and by freshness otherwise.
+	Sort string
+	// SortSpec orders the results by the numeric value of a spec such as
+	// "weight" instead; parts without the spec come last
+	SortSpec string
+	// SortDesc reverses the price and spec orders
+	SortDesc bool
+	Offset   int
+	Limit    int
+	// Summary leaves out specs and images, for lightweight list views
+	Summary bool
+	// Facets names the facets to count over all matching parts: Facet
+	// constants or spec keys
+	Facets []string
+}
+
+// Facets of search results besides spec attributes
+const (
+	FacetBrand       = "brand"
+	FacetCategory    = "category"
+	FacetSubCategory = "sub_category"
+	FacetSource      = "source"
+	FacetInStock     = "in_stock"
+	FacetPrice       = "price"
+)
+
+// maxFacetValues is the most values counted per facet
+const maxFacetValues = 20
+
+// priceBuckets are the bounds between the buckets of the price facet
+var priceBuckets = []float64{25, 50, 100, 250, 500, 1000}
+
+// Fuzzy search settings: searches with fewer exact matches than
+// fuzzyMinHits also return parts whose brand and model have a trigram word
+// similarity of at least fuzzyThreshold to the query
+const (
+	fuzzyMinHits   = 3
+	fuzzyThreshold = 0.4
+)
+
+// partName is the text fuzzy searches match. It must match the expression
+// of the parts_trgm_idx index for searches to use it.
+const partName = `(brand || ' ' || model)`
+
+// SearchResult is a page of parts found by a search
+type SearchResult struct {
+	Parts []models.Part
+	// Fuzzy is set when too few parts matched the query exactly, so parts
+	// with a similar brand and model were included after the exact matches
+	Fuzzy bool
+	// Suggestion is the query with words that are not brand or model words
+	// replaced by the most similar ones, when a search is fuzzy and any were
+	Suggestion string
+	// Facets are the counts of the facets asked for, in the same order
+	Facets []models.Facet
+}
+
+// querier runs queries on a connection pool or in a transaction
+type querier interface {
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+	SendBatch(ctx context.Context, b *pgx.Batch) pgx.BatchResults
+}
+
+// SearchParts searches for parts based on query parameters. With a query,
+// each part comes with a snippet of its description in which the matching
+// words are wrapped in <mark> tags; the rest of the snippet is HTML-escaped.
+func (c *PostgresClient) SearchParts(ctx context.Context, opts SearchOptions) (SearchResult, error) {
+	var result SearchResult
+
+	// Fall back to fuzzy matching when few parts match the query exactly
+	if opts.Query != "" {
+		q := &searchQuery{}
+		q.filter(opts)
+		q.where += " AND " + partDocument + " @@ " + q.tsquery(opts.Query)
+
+		var hits int
+		err := c.pool.QueryRow(ctx, "SELECT count(*) FROM (SELECT 1 FROM parts"+q.where+
+			" LIMIT "+q.arg(fuzzyMinHits)+") hits", q.args...).Scan(&hits)
+		if err != nil {
+			return result, fmt.Errorf("counting search hits: %w", err)
+		}
+
+		if hits < fuzzyMinHits {
+			result.Fuzzy = true
+			if result.Suggestion, err = c.suggestQuery(ctx, opts.Query); err != nil {
+				return result, fmt.Errorf("suggesting query: %w", err)
+			}
+		}
+	}
+
+	parts, facets, err := c.searchParts(ctx, opts, result.Fuzzy)
+	if err != nil {
+		return result, err
+	}
+	result.Parts = parts
+	result.Facets = facets
+
+	return result, nil
+}
+
+// searchParts runs a search and counts its facets, also matching parts by
+// trigram similarity if fuzzy is set
+func (c *PostgresClient) searchParts(ctx context.Context, opts SearchOptions, fuzzy bool) ([]models.Part, []models.Facet, error) {
+	q := &searchQuery{}
+
+	// Add filters
+	q.filter(opts)
+	snippet := "''"
+	rank := ""
+	if opts.Query != "" {
+		tsquery := q.tsquery(opts.Query)
+		match := partDocument + " @@ " + tsquery
+		rank = "ts_rank(" + partDocument + ", " + tsquery + ") DESC"
+		if fuzzy {
+			query := q.arg(fuzzyText(opts.Query))
+			q.where += " AND (" + match + " OR " + query + " <% " + partName + ")"
+			rank = "(" + match + ") DESC, word_similarity(" + query + ", " + partName + ") DESC, " + rank
+		} else {
+			q.where += " AND " + match
+		}
+		snippet = "ts_headline('english', " +
+			`replace(replace(replace(COALESCE(description, ''), '&', '&amp;'), '<', '&lt;'), '>', '&gt;'), ` +
+			tsquery + ", '" + snippetOptions + "')"
+	}
+
+	// Facets are counted over every part the filters match
+	where, whereArgs := q.where, slices.Clone(q.args)
+
+	// Add ordering
+	direction := "ASC"
+	if opts.SortDesc {
+		direction = "DESC"
+	}
+	var order string
+	switch {
+	case opts.SortSpec != "":
+		order = " ORDER BY (SELECT MIN(ps.numeric_value) FROM part_specs ps WHERE ps.part_id = parts.id AND ps.key = " +
+			q.arg(opts.SortSpec) + ") " + direction + " NULLS LAST, created_at DESC"
+	case opts.Sort == SortPrice:
+		order = " ORDER BY price " + direction + ", created_at DESC"
+	case opts.Sort == SortDiscount:
+		order = " ORDER BY " + discountColumn + " DESC, created_at DESC"
+	case opts.Sort == SortRating:
+		order = " ORDER BY rating DESC NULLS LAST, num_reviews DESC NULLS LAST, created_at DESC"
+	case rank != "" && opts.Sort != SortFreshness:
+		order = " ORDER BY " + rank + ", created_at DESC"
+	default:
+		order = " ORDER BY created_at DESC"
+	}
+
+	// Add pagination
+	page := " LIMIT " + q.arg(opts.Limit) + " OFFSET " + q.arg(opts.Offset)
+	sqlQuery := "SELECT " + partColumns + ", " + snippet + " FROM parts" + q.where + order + page
+
+	// Execute the query, for fuzzy searches in a transaction setting the
+	// similarity threshold of the <% operator
+	var db querier = c.pool
+	if fuzzy {
+		tx, err := c.pool.Begin(ctx)
+		if err != nil {
+			return nil, nil, err
+		}
+		defer tx.Rollback(ctx)
+
+		_, err = tx.Exec(ctx, "SELECT set_config('pg_trgm.word_similarity_threshold', $1, true)",
+			strconv.FormatFloat(fuzzyThreshold, 'f', -1, 64))
+		if err != nil {
+			return nil, nil, fmt.Errorf("setting similarity threshold: %w", err)
+		}
+		db = tx
+	}
+
+	rows, err := db.Query(ctx, sqlQuery, q.args...)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+
+	var parts []models.Part
+	for rows.Next() {
+		var part models.Part
+		if err := rows.Scan(append(partFields(&part), &part.Snippet)...); err != nil {
+			return nil, nil, err
+		}
+		parts = append(parts, part)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	facets, err := countFacets(ctx, db, opts.Facets, where, whereArgs)
+	if err != nil {
+		return nil, nil, fmt.Errorf("counting facets: %w", err)
+	}
+
+	// Load specs and images for all parts at once
+	if opts.Summary {
+		return parts, facets, nil
+	}
+	if err := c.loadPartDetails(ctx, parts); err != nil {
+		return nil, nil, err
+	}
+
+	return parts, facets, nil
+}
+
+// countFacets counts the parts matching a WHERE clause by the values of
+// each facet, in one round trip
+func countFacets(ctx context.Context, db querier, names []string, where string, args []any) ([]models.Facet, error) {
+	if len(names) == 0 {
+		return nil, nil
+	}
+
+	batch := &pgx.Batch{}
+	for _, name := range names {
+		limit := " LIMIT " + strconv.Itoa(maxFacetValues)
+		var sql string
+		switch name {
+		case FacetBrand, FacetCategory, FacetSource:
+			sql = "SELECT " + name + ", count(*) FROM parts" + where +
+				" GROUP BY 1 ORDER BY 2 DESC, 1" + limit
+		case FacetSubCategory:
+			sql = "SELECT sub_category, count(*) FROM parts" + where +
+				" AND sub_category <> '' GROUP BY 1 ORDER BY 2 DESC, 1" + limit
+		case FacetInStock:
+			sql = "SELECT in_stock::text, count(*) FROM parts" + where + " GROUP BY 1 ORDER BY 2 DESC, 1"
+		case FacetPrice:
+			sql = "SELECT width_bucket(price, " + priceBucketsArray() + ")::text, count(*) FROM parts" + where +
+				" GROUP BY 1 ORDER BY min(price)"
+		default:
+			// Numeric values of spec attributes, and the text of other specs
+			sql = "SELECT COALESCE(ps.numeric_value::text, ps.value), count(DISTINCT ps.part_id) FROM part_specs ps" +
+				" WHERE ps.key = $" + strconv.Itoa(len(args)+1) + " AND ps.part_id IN (SELECT id FROM parts" + where + ")" +
+				" GROUP BY 1 ORDER BY 2 DESC, 1" + limit
+			batch.Queue(sql, append(slices.Clone(args), name)...)
+			continue
+		}
+		batch.Queue(sql, args...)
+	}
+
+	results := db.SendBatch(ctx, batch)
+	defer results.Close()
+
+	facets := make([]models.Facet, 0, len(names))
+	for _, name := range names {
+		rows, err := results.Query()
+		if err != nil {
+			return nil, err
+		}
+		facet := models.Facet{Name: name, Values: []models.FacetValue{}}
+		for rows.Next() {
+			var value models.FacetValue
+			if err := rows.Scan(&value.Value, &value.Count); err != nil {
+				rows.Close()
+				return nil, err
+			}
+			if name == FacetPrice {
+				bucket, _ := strconv.Atoi(value.Value)
+				value = priceBucketValue(bucket, value.Count)
+			}
+			facet.Values = append(facet.Values, value)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+		facets = append(facets, facet)
+	}
+
+	return facets, results.Close()
+}
+
+// priceBucketsArray returns priceBuckets as a SQL array for width_bucket
+func priceBucketsArray() string {
+	bounds := make([]string, len(priceBuckets))
+	for i, bound := range priceBuckets {
+		bounds[i] = strconv.FormatFloat(bound, 'f', -1, 64)
+	}
+	return "ARRAY[" + strings.Join(bounds, ", ") + "]::numeric[]"
+}
+
+// priceBucketValue returns the facet value of a price bucket
+func priceBucketValue(bucket, count int) models.FacetValue {
+	value := models.FacetValue{Count: count, Min: new(float64)}
+	if bucket > 0 {
+		*value.Min = priceBuckets[bucket-1]
+	}
+	if bucket < len(priceBuckets) {
+		value.Max = &priceBuckets[bucket]
+		value.Value = fmt.Sprintf("%g-%g", *value.Min, *value.Max)
+	} else {
+		value.Value = fmt.Sprintf("%g+", *value.Min)
+	}
+	return value
+}
+
+// HasSpecKey reports whether any part has a spec with a key
+func (c *PostgresClient) HasSpecKey(ctx context.Context, key string) (bool, error) {
+	var exists bool
+	err := c.pool.QueryRow(ctx, "SELECT EXISTS (SELECT 1 FROM part_specs WHERE key = $1)", key).Scan(&exists)
+	if err != nil {
+		return false, fmt.Errorf("looking up spec key %s: %w", key, err)
+	}
+	return exists, nil
+}
+
+// suggestQuery returns the query with each word that no brand or model
+// word starts with replaced by the most similar brand or model word, or ""
+// if no word was replaced
+func (c *PostgresClient) suggestQuery(ctx context.Context, query string) (string, error) {
+	// The % operator uses the default pg_trgm.similarity_threshold of 0.3
+	rows, err := c.pool.Query(ctx, `
+		SELECT q.term, s.term
+		FROM unnest($1::text[]) q(term)
+		CROSS JOIN LATERAL (
+			SELECT t.term FROM search_terms t
+			WHERE t.term % q.term
+			ORDER BY similarity(t.term, q.term) DESC, t.term
+			LIMIT 1
+		) s
+		WHERE NOT EXISTS (SELECT 1 FROM search_terms t WHERE t.term LIKE q.term || '%')
+	`, suggestionTerms(query))
+	if err != nil {
+		return "", err
+	}
+	defer rows.Close()
+
+	corrections := make(map[string]string)
+	for rows.Next() {
+		var term, correction string
+		if err := rows.Scan(&term, &correction); err != nil {
+			return "", err
+		}
+		corrections[term] = correction
+	}
+	if err := rows.Err(); err != nil {
+		return "", err
+	}
+
+	return correctQuery(query, corrections), nil
+}
+
+// searchQuery is the WHERE clause and arguments of a search being built
+type searchQuery struct {
+	where string
+	args  []any
+}
+
+// arg adds an argument and returns its placeholder
+func (q *searchQuery) arg(v any) string {
+	q.args = append(q.args, v)
+	return "$" + strconv.Itoa(len(q.args))
+}
+
+// tsquery adds a text query and returns the tsquery expression matching it
+func (q *searchQuery) tsquery(query string) string {
+	if prefix := prefixQuery(query); prefix != "" {
+		return "to_tsquery('english', " + q.arg(prefix) + ")"
+	}
+	return "websearch_to_tsquery('english', " + q.arg(query) + ")"
+}
+
+// filter adds the filters of a search other than its text query
+func (q *searchQuery) filter(opts SearchOptions) {
+	q.where = " WHERE 1=1"
+
+	if opts.Brand != "" {
+		q.where += " AND brand ILIKE " + q.arg("%"+opts.Brand+"%")
+	}
+
+	if opts.Category != "" {
+		q.where += " AND category ILIKE " + q.arg("%"+opts.Category+"%")
+	}
+
+	if opts.SubCategory != "" {
+		q.where += " AND sub_category ILIKE " + q.arg("%"+opts.SubCategory+"%")
+	}
+
+	if opts.Source != "" {
+		q.where += " AND lower(source) = lower(" + q.arg(opts.Source) + ")"
+	}
+
+	if opts.InStock != nil {
+		q.where += " AND in_stock = " + q.arg(*opts.InStock)
+	}
+
+	if opts.MinPrice != nil {
+		q.where += " AND price >= " + q.arg(*opts.MinPrice)
+	}
+	if opts.MaxPrice != nil {
+		q.where += " AND price <= " + q.arg(*opts.MaxPrice)
+	}
+
+	if opts.MinDiscount != nil {
+		q.where += " AND " + discountColumn + " >= " + q.arg(*opts.MinDiscount)
+	}
+
+	if opts.MinRating != nil {
+		q.where += " AND rating >= " + q.arg(*opts.MinRating)
+	}
+
+	for _, filter := range opts.Specs {
+		q.where += " AND EXISTS (SELECT 1 FROM part_specs ps WHERE ps.part_id = parts.id AND ps.key = " + q.arg(filter.Key)
+		if filter.Value != "" {
+			q.where += " AND lower(ps.value) = lower(" + q.arg(filter.Value) + ")"
+		}
+		if filter.Min != nil {
+			q.where += " AND ps.numeric_value >= " + q.arg(*filter.Min)
+		}
+		if filter.Max != nil {
+			q.where += " AND ps.numeric_value <= " + q.arg(*filter.Max)
+		}
+		q.where += ")"
+	}
+}
+
+// prefixQuery turns a plain query of words into a tsquery requiring every
+// word and matching the last one as a prefix, so that "shimano xt brak"
+// finds brakes while it is being typed. It returns "" for queries using
+// web search syntax, which websearch_to_tsquery handles instead.
+func prefixQuery(query string) string {
+	if strings.Contains(query, `"`) {
+		return ""
+	}
+	for _, word := range strings.Fields(query) {
+		if strings.HasPrefix(word, "-") || strings.EqualFold(word, "or") {
+			return ""
+		}
+	}
+
+	terms := queryTerms(query)
+	if len(terms) == 0 {
+		return ""
+	}
+	terms[len(terms)-1] += ":*"
+	return strings.Join(terms, " & ")
+}
+
+// queryClause is one alternative of a web search style query: the words
+// and phrases a part needs and the words it must not have
+type queryClause struct {
+	words    []string
+	phrases  []string
+	excluded []string
+}
+
+// parseTextQuery parses a query like websearch_to_tsquery does: "or"
+// separates alternatives, "quoted text" is a phrase and -word excludes
+func parseTextQuery(query string) []queryClause {
+	var clauses []queryClause
+	var clause queryClause
+	for rest := strings.TrimSpace(query); rest != ""; rest = strings.TrimSpace(rest) {
+		if rest[0] == '"' {
+			phrase, after, _ := strings.Cut(rest[1:], `"`)
+			if phrase = strings.Join(queryTerms(phrase), " "); phrase != "" {
+				clause.phrases = append(clause.phrases, phrase)
+			}
+			rest = after
+			continue
+		}
+
+		word := rest
+		if i := strings.IndexFunc(rest, unicode.IsSpace); i >= 0 {
+			word, rest = rest[:i], rest[i:]
+		} else {
+			rest = ""
+		}
+		switch {
+		case strings.EqualFold(word, "or"):
+			clauses = append(clauses, clause)
+			clause = queryClause{}
+		case strings.HasPrefix(word, "-"):
+			clause.excluded = append(clause.excluded, queryTerms(word)...)
+		default:
+			clause.words = append(clause.words, queryTerms(word)...)
+		}
+	}
+	return append(clauses, clause)
+}
+
+// fuzzyText returns the words and phrases a query asks for, leaving out
+// excluded words and web search syntax, for matching by similarity
+func fuzzyText(query string) string {
+	var words []string
+	for _, clause := range parseTextQuery(query) {
+		words = append(words, clause.words...)
+		words = append(words, clause.phrases...)
+	}
+	return strings.Join(words, " ")
+}
+
+// queryTerms splits a query into lowercase words of letters and digits
+func queryTerms(query string) []string {
+	return strings.FieldsFunc(strings.ToLower(query), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// queryWordPattern matches the words of a query
+var queryWordPattern = regexp.MustCompile(`[\pL\pN]+`)
+
+// correctQuery replaces the words of a query that have a correction, keeping
+// the rest of the query as written, or returns "" if none has
+func correctQuery(query string, corrections map[string]string) string {
+	corrected := false
+	query = queryWordPattern.ReplaceAllStringFunc(query, func(word string) string {
+		if correction, ok := corrections[strings.ToLower(word)]; ok {
+			corrected = true
+			return correction
+		}
+		return word
+	})
+	if !corrected {
+		return ""
+	}
+	return query
+}
+
+// suggestionTerms returns the words of a query that may be corrected,
+// leaving out the "or" of web search syntax
+func suggestionTerms(query string) []string {
+	var terms []string
+	for _, term := range queryTerms(query) {
+		if term != "or" {
+			terms = append(terms, term)
+		}
+	}
+	return terms
+}
+
+// searchTerms returns the words of the brand and model of a part that
+// suggestions are made from
+func searchTerms(part models.Part) []string {
+	var terms []string
+	for _, term := range queryTerms(part.Brand + " " + part.Model) {
+		if n := utf8.RuneCountInString(term); n >= 2 && n <= 100 && strings.IndexFunc(term, unicode.IsLetter) >= 0 {
+			terms = append(terms, term)
+		}
+	}
+	return terms
+}
@@ -0,0 +1,236 @@
+package database
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sosadtsia/bike-parts-finder/pkg/models"
+)
+
+// searchTestParts are stored by newSearchTestClient, oldest first
+var searchTestParts = []models.Part{
+	{
+		ID: "search-xt-brake", Brand: "Shimano", Model: "XT M8120 Brake", Category: "Brakes",
+		Price: 129.99, InStock: true, Description: "Four piston hydraulic disc brake for trail riding",
+	},
+	{
+		ID: "search-slx-cassette", Brand: "Shimano", Model: "SLX M7100 Cassette", Category: "Drivetrain",
+		Price: 89.99, Description: "12-speed cassette with a 10-51t range",
+		Specs: []models.Spec{{Name: "Speed", Value: "12", Key: "speeds", NumericValue: ptr(12.0)}},
+	},
+	{
+		ID: "search-xt-cassette", Brand: "Shimano", Model: "XT M8100 Cassette", Category: "Drivetrain",
+		Price: 149.99, InStock: true, Description: "Wide range for trail riding",
+		Specs: []models.Spec{{Name: "Speed", Value: "12", Key: "speeds", NumericValue: ptr(12.0)}},
+	},
+	{
+		ID: "search-gx-cassette", Brand: "SRAM", Model: "GX Eagle Cassette", Category: "Drivetrain",
+		Price: 1100, InStock: true, Description: "Eagle cassette with a cassette lockring, <b>new</b>",
+		Specs: []models.Spec{{Name: "Speed", Value: "12", Key: "speeds", NumericValue: ptr(12.0)}},
+	},
+	{
+		ID: "search-deore-chain", Brand: "Shimano", Model: "Deore CN-M6100 Chain", Category: "Drivetrain",
+		Price: 30, Description: "Chain with a quick link",
+		Specs: []models.Spec{{Name: "Speed", Value: "11", Key: "speeds", NumericValue: ptr(11.0)}},
+	},
+}
+
+// newSearchTestClient connects to the test database and stores
+// searchTestParts
+func newSearchTestClient(t *testing.T) *PostgresClient {
+	t.Helper()
+	c := newTestClient(t)
+
+	created := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	parts := make([]models.Part, len(searchTestParts))
+	for i, part := range searchTestParts {
+		part.Currency = "USD"
+		part.URL = "https://example.com/products/" + part.ID
+		part.Source = testSource
+		part.CreatedAt = created.Add(time.Duration(i) * time.Hour)
+		parts[i] = part
+	}
+	if err := c.StoreParts(context.Background(), parts); err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+// searchTest searches the parts stored by newSearchTestClient
+func searchTest(t *testing.T, c *PostgresClient, opts SearchOptions) SearchResult {
+	t.Helper()
+	opts.Source = testSource
+	if opts.Limit == 0 {
+		opts.Limit = 10
+	}
+	result, err := c.SearchParts(context.Background(), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return result
+}
+
+func resultIDs(parts []models.Part) []string {
+	ids := make([]string, len(parts))
+	for i, part := range parts {
+		ids[i] = part.ID
+	}
+	return ids
+}
+
+func TestSearchPartsRanking(t *testing.T) {
+	c := newSearchTestClient(t)
+
+	// Parts mentioning the query more often rank higher
+	result := searchTest(t, c, SearchOptions{Query: "cassette"})
+	want := []string{"search-gx-cassette", "search-slx-cassette", "search-xt-cassette"}
+	if ids := resultIDs(result.Parts); !slices.Equal(ids, want) || result.Fuzzy {
+		t.Errorf("got %v, fuzzy %v; want %v", ids, result.Fuzzy, want)
+	}
+
+	// Freshness overrides relevance
+	result = searchTest(t, c, SearchOptions{Query: "cassette", Sort: SortFreshness})
+	want = []string{"search-gx-cassette", "search-xt-cassette", "search-slx-cassette"}
+	if ids := resultIDs(result.Parts); !slices.Equal(ids, want) {
+		t.Errorf("by freshness: got %v, want %v", ids, want)
+	}
+
+	// Too few exact matches are followed by similar parts, so only the
+	// first results are the exact matches
+	tests := []struct {
+		query string
+		exact []string
+	}{
+		// The last word of a plain query matches as a prefix
+		{"shimano cass", []string{"search-slx-cassette", "search-xt-cassette"}},
+		// Web search syntax excludes words
+		{"cassette -eagle", []string{"search-slx-cassette", "search-xt-cassette"}},
+		{`"disc brake" or chain`, []string{"search-deore-chain", "search-xt-brake"}},
+	}
+	for _, tt := range tests {
+		ids := resultIDs(searchTest(t, c, SearchOptions{Query: tt.query}).Parts)
+		if len(ids) < len(tt.exact) {
+			t.Errorf("%s: got %v, want %v first", tt.query, ids, tt.exact)
+			continue
+		}
+		first := slices.Sorted(slices.Values(ids[:len(tt.exact)]))
+		if !slices.Equal(first, tt.exact) {
+			t.Errorf("%s: got %v, want %v first", tt.query, ids, tt.exact)
+		}
+	}
+}
+
+func TestSearchPartsFuzzy(t *testing.T) {
+	c := newSearchTestClient(t)
+
+	result := searchTest(t, c, SearchOptions{Query: "shimno casette"})
+	if !result.Fuzzy {
+		t.Error("search is not fuzzy")
+	}
+	if result.Suggestion != "shimano cassette" {
+		t.Errorf("suggestion %q, want %q", result.Suggestion, "shimano cassette")
+	}
+
+	// The most similar names come first; names too far off are left out
+	ids := resultIDs(result.Parts)
+	if len(ids) != 3 {
+		t.Fatalf("got %v, want the three cassettes", ids)
+	}
+	first := slices.Sorted(slices.Values(ids[:2]))
+	if want := []string{"search-slx-cassette", "search-xt-cassette"}; !slices.Equal(first, want) || ids[2] != "search-gx-cassette" {
+		t.Errorf("got %v, want %v then search-gx-cassette", ids, want)
+	}
+}
+
+func TestSearchPartsSnippet(t *testing.T) {
+	c := newSearchTestClient(t)
+
+	result := searchTest(t, c, SearchOptions{Query: "hydraulic"})
+	if len(result.Parts) == 0 || result.Parts[0].ID != "search-xt-brake" {
+		t.Fatalf("got %v, want search-xt-brake first", resultIDs(result.Parts))
+	}
+	if snippet := result.Parts[0].Snippet; !strings.Contains(snippet, "<mark>hydraulic</mark>") {
+		t.Errorf("snippet %q does not highlight the query", snippet)
+	}
+
+	// Markup in descriptions is escaped
+	result = searchTest(t, c, SearchOptions{Query: "lockring"})
+	if len(result.Parts) == 0 {
+		t.Fatal("no parts found")
+	}
+	if snippet := result.Parts[0].Snippet; strings.Contains(snippet, "<b>") || !strings.Contains(snippet, "&lt;b&gt;") {
+		t.Errorf("snippet %q, want the description's markup escaped", snippet)
+	}
+
+	// Searches without a query have no snippets
+	for _, part := range searchTest(t, c, SearchOptions{}).Parts {
+		if part.Snippet != "" {
+			t.Errorf("part %s has snippet %q without a query", part.ID, part.Snippet)
+		}
+	}
+}
+
+func TestSearchPartsFacets(t *testing.T) {
+	c := newSearchTestClient(t)
+
+	result := searchTest(t, c, SearchOptions{
+		Facets: []string{FacetBrand, FacetPrice, FacetInStock, "speeds"},
+		Limit:  1,
+	})
+	if len(result.Parts) != 1 {
+		t.Errorf("got %d parts, want 1", len(result.Parts))
+	}
+
+	// Facets count every matching part, not only the page
+	want := map[string][]models.FacetValue{
+		FacetBrand:   {{Value: "Shimano", Count: 4}, {Value: "SRAM", Count: 1}},
+		FacetPrice:   {{Value: "25-50", Count: 1}, {Value: "50-100", Count: 1}, {Value: "100-250", Count: 2}, {Value: "1000+", Count: 1}},
+		FacetInStock: {{Value: "true", Count: 3}, {Value: "false", Count: 2}},
+		"speeds":     {{Value: "12", Count: 3}, {Value: "11", Count: 1}},
+	}
+	if len(result.Facets) != len(want) {
+		t.Fatalf("got %d facets, want %d", len(result.Facets), len(want))
+	}
+	for _, facet := range result.Facets {
+		values := want[facet.Name]
+		if len(facet.Values) != len(values) {
+			t.Errorf("facet %s: got %+v, want %+v", facet.Name, facet.Values, values)
+			continue
+		}
+		for i, value := range facet.Values {
+			if value.Value != values[i].Value || value.Count != values[i].Count {
+				t.Errorf("facet %s: got %+v, want %+v", facet.Name, facet.Values, values)
+				break
+			}
+		}
+	}
+
+	// Filters and queries narrow the counts
+	result = searchTest(t, c, SearchOptions{Query: "cassette", InStock: ptr(true), Facets: []string{FacetBrand}})
+	brands := result.Facets[0].Values
+	if len(brands) != 2 || brands[0].Count != 1 || brands[1].Count != 1 {
+		t.Errorf("got brands %+v, want one Shimano and one SRAM cassette", brands)
+	}
+}
+
+func TestPrefixQuery(t *testing.T) {
+	tests := []struct {
+		query string
+		want  string
+	}{
+		{"shimano xt brak", "shimano & xt & brak:*"},
+		{"Deore-XT", "deore & xt:*"},
+		{`"xt brake"`, ""},
+		{"brake -rotor", ""},
+		{"xt or slx", ""},
+		{"  ", ""},
+	}
+	for _, tt := range tests {
+		if got := prefixQuery(tt.query); got != tt.want {
+			t.Errorf("prefixQuery(%q) = %q, want %q", tt.query, got, tt.want)
+		}
+	}
+}
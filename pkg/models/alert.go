@@ -0,0 +1,76 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Alert rule types
+const (
+	// AlertPriceBelow fires when the price falls to or below Threshold
+	AlertPriceBelow = "price_below"
+	// AlertPriceDrop fires when the price falls DropPercent or more below
+	// the price when the rule was created
+	AlertPriceDrop = "price_drop"
+	// AlertBackInStock fires when an out of stock part is back in stock
+	AlertBackInStock = "back_in_stock"
+)
+
+// Alert delivery statuses
+const (
+	DeliveryPending   = "pending"
+	DeliveryDelivered = "delivered"
+	DeliveryFailed    = "failed"
+)
+
+// AlertRule notifies a webhook when a part's price or stock meets a
+// condition. A rule fires once when its condition becomes true and is
+// re-armed when the condition no longer holds.
+type AlertRule struct {
+	ID              string     `json:"id"`
+	PartID          string     `json:"part_id"`
+	Type            string     `json:"type"`
+	Threshold       float64    `json:"threshold,omitempty"`
+	DropPercent     float64    `json:"drop_percent,omitempty"`
+	BaselinePrice   float64    `json:"baseline_price,omitempty"`
+	WebhookURL      string     `json:"webhook_url,omitempty"`
+	Secret          string     `json:"secret,omitempty"`
+	Triggered       bool       `json:"triggered"`
+	LastTriggeredAt *time.Time `json:"last_triggered_at,omitempty"`
+	CreatedAt       time.Time  `json:"created_at"`
+}
+
+// AlertEvent is the payload sent to a webhook when a rule fires
+type AlertEvent struct {
+	AlertID       string    `json:"alert_id"`
+	Type          string    `json:"type"`
+	PartID        string    `json:"part_id"`
+	Brand         string    `json:"brand"`
+	Model         string    `json:"model"`
+	URL           string    `json:"url"`
+	Price         float64   `json:"price"`
+	Currency      string    `json:"currency"`
+	InStock       bool      `json:"in_stock"`
+	Threshold     float64   `json:"threshold,omitempty"`
+	BaselinePrice float64   `json:"baseline_price,omitempty"`
+	TriggeredAt   time.Time `json:"triggered_at"`
+}
+
+// AlertDelivery is a webhook delivery of an alert event and the outcome of
+// its latest attempt
+type AlertDelivery struct {
+	ID             int64           `json:"id"`
+	AlertID        string          `json:"alert_id"`
+	Payload        json.RawMessage `json:"payload"`
+	Status         string          `json:"status"`
+	Attempts       int             `json:"attempts"`
+	LastStatusCode int             `json:"last_status_code,omitempty"`
+	LastError      string          `json:"last_error,omitempty"`
+	NextAttemptAt  *time.Time      `json:"next_attempt_at,omitempty"`
+	CreatedAt      time.Time       `json:"created_at"`
+	DeliveredAt    *time.Time      `json:"delivered_at,omitempty"`
+
+	// Webhook of the rule, set for deliveries claimed for sending
+	WebhookURL string `json:"-"`
+	Secret     string `json:"-"`
+}
@@ -0,0 +1,33 @@
+package models
+
+import "time"
+
+// Category is a node of the canonical category taxonomy. IDs are slug
+// paths such as "drivetrain/cassettes".
+type Category struct {
+	ID        string     `json:"id"`
+	Name      string     `json:"name"`
+	ParentID  string     `json:"parent_id,omitempty"`
+	Position  int        `json:"-"`
+	PartCount int        `json:"part_count"`
+	Children  []Category `json:"children,omitempty"`
+}
+
+// CategoryMapping maps a retailer breadcrumb path to a taxonomy node. The
+// rule applies to parts whose breadcrumbs contain Breadcrumbs as a
+// consecutive run; Source "*" applies it to every source.
+type CategoryMapping struct {
+	Source      string   `json:"source"`
+	Breadcrumbs []string `json:"breadcrumbs"`
+	CategoryID  string   `json:"category_id"`
+}
+
+// UnmappedCategory is a retailer breadcrumb path no mapping rule matched
+type UnmappedCategory struct {
+	Source      string    `json:"source"`
+	Breadcrumbs []string  `json:"breadcrumbs"`
+	Occurrences int       `json:"occurrences"`
+	ExampleURL  string    `json:"example_url"`
+	FirstSeen   time.Time `json:"first_seen"`
+	LastSeen    time.Time `json:"last_seen"`
+}
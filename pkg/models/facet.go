@@ -0,0 +1,18 @@
+package models
+
+// Facet counts the parts of a search result by the values of a field or
+// spec attribute, most common values first
+type Facet struct {
+	Name   string       `json:"name"`
+	Values []FacetValue `json:"values"`
+}
+
+// FacetValue is a value of a facet and how many parts have it. Buckets of
+// numbers such as prices also have their bounds: Min is inclusive and Max,
+// unset for the last bucket, exclusive.
+type FacetValue struct {
+	Value string   `json:"value"`
+	Count int      `json:"count"`
+	Min   *float64 `json:"min,omitempty"`
+	Max   *float64 `json:"max,omitempty"`
+}
@@ -0,0 +1,46 @@
+package models
+
+import "strings"
+
+// NormalizeGTIN validates a GTIN-8, UPC-A (GTIN-12), EAN-13 or GTIN-14 and
+// returns it zero-padded to 14 digits, so that the same product matches
+// whichever form a retailer publishes. Spaces and hyphens are ignored.
+func NormalizeGTIN(code string) (string, bool) {
+	code = strings.Map(func(r rune) rune {
+		if r == ' ' || r == '-' {
+			return -1
+		}
+		return r
+	}, code)
+
+	switch len(code) {
+	case 8, 12, 13, 14:
+	default:
+		return "", false
+	}
+	for _, r := range code {
+		if r < '0' || r > '9' {
+			return "", false
+		}
+	}
+
+	code = strings.Repeat("0", 14-len(code)) + code
+	if !validGTINCheckDigit(code) {
+		return "", false
+	}
+	return code, true
+}
+
+// validGTINCheckDigit checks the GS1 mod-10 check digit of a 14-digit GTIN
+func validGTINCheckDigit(code string) bool {
+	sum := 0
+	for i := 0; i < 13; i++ {
+		digit := int(code[i] - '0')
+		// Weights alternate 3, 1, ... from the leftmost digit of a GTIN-14
+		if i%2 == 0 {
+			digit *= 3
+		}
+		sum += digit
+	}
+	return (10-sum%10)%10 == int(code[13]-'0')
+}
@@ -0,0 +1,40 @@
+package models
+
+import "testing"
+
+func TestNormalizeGTIN(t *testing.T) {
+	tests := []struct {
+		code string
+		want string
+		ok   bool
+	}{
+		// GTIN-8
+		{"96385074", "00000096385074", true},
+		{"96385075", "", false},
+		// UPC-A
+		{"036000291452", "00036000291452", true},
+		{"036000291453", "", false},
+		// EAN-13
+		{"4006381333931", "04006381333931", true},
+		{"4006381333932", "", false},
+		// GTIN-14
+		{"10012345678902", "10012345678902", true},
+		{"10012345678909", "", false},
+		// Spaces and hyphens are ignored
+		{"4 006381 333931", "04006381333931", true},
+		{"036000-29145-2", "00036000291452", true},
+		{" 9638-5074 ", "00000096385074", true},
+		// Other characters and lengths are rejected
+		{"400638133393A", "", false},
+		{"4006381.333931", "", false},
+		{"0360002914", "", false},
+		{"100123456789021", "", false},
+		{"", "", false},
+	}
+	for _, tt := range tests {
+		got, ok := NormalizeGTIN(tt.code)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("NormalizeGTIN(%q) = %q, %v; want %q, %v", tt.code, got, ok, tt.want, tt.ok)
+		}
+	}
+}
@@ -2,13 +2,23 @@ package models
 
 import "time"
 
-// Part represents a bicycle part
+// Part represents a bicycle part. GTIN is the normalized 14-digit form of
+// the product's UPC/EAN, MPN the manufacturer part number and SKU the
+// retailer's own item number. Breadcrumbs is the retailer's category path;
+// Category and SubCategory hold the canonical taxonomy names once the path
+// is mapped to the taxonomy node CategoryID. Snippet is only set in search
+// results, as HTML with the matching words of the description highlighted.
 type Part struct {
 	ID          string    `json:"id"`
 	Brand       string    `json:"brand"`
 	Model       string    `json:"model"`
+	GTIN        string    `json:"gtin,omitempty"`
+	MPN         string    `json:"mpn,omitempty"`
+	SKU         string    `json:"sku,omitempty"`
 	Category    string    `json:"category"`
 	SubCategory string    `json:"sub_category"`
+	CategoryID  string    `json:"category_id,omitempty"`
+	Breadcrumbs []string  `json:"breadcrumbs,omitempty"`
 	Price       float64   `json:"price"`
 	MSRP        float64   `json:"msrp,omitempty"`
 	Discount    float64   `json:"discount,omitempty"`
@@ -17,32 +27,82 @@ type Part struct {
 	Rating      float64   `json:"rating,omitempty"`
 	NumReviews  int       `json:"num_reviews,omitempty"`
 	Description string    `json:"description"`
+	Snippet     string    `json:"snippet,omitempty"`
 	Images      []string  `json:"images,omitempty"`
 	URL         string    `json:"url"`
 	Source      string    `json:"source,omitempty"`
 	Specs       []Spec    `json:"specs,omitempty"`
+	Variants    []Variant `json:"variants,omitempty"`
 	CreatedAt   time.Time `json:"created_at,omitempty"`
 	UpdatedAt   time.Time `json:"updated_at,omitempty"`
 }
 
-// Spec represents a specification of a part
+// Spec represents a specification of a part. Name and Value are the
+// retailer's text; Key is the canonical attribute and, when the value could
+// be parsed, NumericValue holds it in Unit.
 type Spec struct {
-	Name  string `json:"name"`
-	Value string `json:"value"`
+	Name         string   `json:"name"`
+	Value        string   `json:"value"`
+	Key          string   `json:"key,omitempty"`
+	NumericValue *float64 `json:"numeric_value,omitempty"`
+	Unit         string   `json:"unit,omitempty"`
 }
 
-// ScrapeRequest represents a request to scrape a URL for bike parts
+// Variant is a purchasable option of a part, such as a size or colour, with
+// its own price and stock. Attributes name the options that distinguish
+// it, e.g. {"Size": "700x28c"}. A part with variants carries the lowest
+// variant price and is in stock when any variant is.
+type Variant struct {
+	Title      string            `json:"title"`
+	Attributes map[string]string `json:"attributes,omitempty"`
+	SKU        string            `json:"sku,omitempty"`
+	GTIN       string            `json:"gtin,omitempty"`
+	Price      float64           `json:"price"`
+	MSRP       float64           `json:"msrp,omitempty"`
+	InStock    bool              `json:"in_stock"`
+	URL        string            `json:"url,omitempty"`
+	Image      string            `json:"image,omitempty"`
+}
+
+// ScrapeRequest represents a request to scrape a URL for bike parts.
+// Zero limits fall back to the scraper's defaults.
 type ScrapeRequest struct {
-	ID        string    `json:"id"`
-	URL       string    `json:"url"`
-	Source    string    `json:"source"`
-	Timestamp time.Time `json:"timestamp"`
+	ID                 string    `json:"id"`
+	URL                string    `json:"url"`
+	Source             string    `json:"source"`
+	MaxPages           int       `json:"max_pages,omitempty"`
+	MaxDepth           int       `json:"max_depth,omitempty"`
+	MaxDurationSeconds int       `json:"max_duration_seconds,omitempty"`
+	Timestamp          time.Time `json:"timestamp"`
 }
 
-// ScrapeResult represents the result of a scraping operation
+// ScrapeResult represents the result of a scraping operation. Truncated is
+// set when the scrape stopped early because a limit was reached or it was
+// cancelled, in which case Parts holds what was collected until then.
+// Errors lists the pages that could not be scraped.
 type ScrapeResult struct {
-	RequestID string    `json:"request_id"`
-	URL       string    `json:"url"`
-	Parts     []Part    `json:"parts"`
-	Timestamp time.Time `json:"timestamp"`
+	RequestID string        `json:"request_id"`
+	URL       string        `json:"url"`
+	Parts     []Part        `json:"parts"`
+	Truncated bool          `json:"truncated,omitempty"`
+	Errors    []ScrapeError `json:"errors,omitempty"`
+	Timestamp time.Time     `json:"timestamp"`
+}
+
+// Kinds of scrape errors
+const (
+	ScrapeErrorNetwork = "network"
+	ScrapeErrorClient  = "client"
+	ScrapeErrorServer  = "server"
+	ScrapeErrorParse   = "parse"
+	ScrapeErrorBlocked = "blocked"
+)
+
+// ScrapeError describes a page that could not be scraped after all attempts
+type ScrapeError struct {
+	URL        string `json:"url"`
+	Kind       string `json:"kind"`
+	StatusCode int    `json:"status_code,omitempty"`
+	Message    string `json:"message"`
+	Attempts   int    `json:"attempts"`
 }
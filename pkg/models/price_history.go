@@ -0,0 +1,28 @@
+package models
+
+import "time"
+
+// PriceObservation is a part's price and stock as observed at a point in
+// time. Observations are only recorded when either changes, so each holds
+// until the next one.
+type PriceObservation struct {
+	Price      float64   `json:"price"`
+	MSRP       float64   `json:"msrp,omitempty"`
+	Currency   string    `json:"currency"`
+	InStock    bool      `json:"in_stock"`
+	ObservedAt time.Time `json:"observed_at"`
+}
+
+// PriceHistory is the price time series of a part over a window with the
+// lowest, highest and time-weighted average price during the window
+type PriceHistory struct {
+	PartID       string             `json:"part_id"`
+	From         time.Time          `json:"from"`
+	To           time.Time          `json:"to"`
+	Currency     string             `json:"currency"`
+	Current      float64            `json:"current"`
+	Min          float64            `json:"min"`
+	Max          float64            `json:"max"`
+	Avg          float64            `json:"avg"`
+	Observations []PriceObservation `json:"observations"`
+}
@@ -0,0 +1,73 @@
+package pricing
+
+import (
+	"math"
+	"time"
+
+	"github.com/sosadtsia/bike-parts-finder/pkg/models"
+)
+
+// History builds the price history of a part between from and to.
+// Observations must be in chronological order and may start with the last
+// observation before from, whose price is in effect at the start of the
+// window. The average weights each price by how long it was in effect.
+// Observations without a price, which were recorded before unparsed prices
+// were carried forward, are skipped, leaving the price before them in effect.
+func History(partID string, observations []models.PriceObservation, from, to time.Time) models.PriceHistory {
+	history := models.PriceHistory{
+		PartID:       partID,
+		From:         from,
+		To:           to,
+		Observations: []models.PriceObservation{},
+	}
+
+	priced := make([]models.PriceObservation, 0, len(observations))
+	for _, o := range observations {
+		if o.Price > 0 {
+			priced = append(priced, o)
+		}
+	}
+	observations = priced
+
+	var weighted, total float64
+	for i, o := range observations {
+		if o.ObservedAt.After(to) {
+			break
+		}
+		start := o.ObservedAt
+		if start.Before(from) {
+			start = from
+		}
+		end := to
+		if i+1 < len(observations) && observations[i+1].ObservedAt.Before(to) {
+			end = observations[i+1].ObservedAt
+		}
+		if end.Before(start) || (end.Equal(start) && o.ObservedAt.Before(from)) {
+			// Superseded before the window starts
+			continue
+		}
+
+		if len(history.Observations) == 0 || o.Price < history.Min {
+			history.Min = o.Price
+		}
+		if len(history.Observations) == 0 || o.Price > history.Max {
+			history.Max = o.Price
+		}
+		history.Observations = append(history.Observations, o)
+		history.Current = o.Price
+		history.Currency = o.Currency
+
+		seconds := end.Sub(start).Seconds()
+		weighted += o.Price * seconds
+		total += seconds
+	}
+
+	switch {
+	case total > 0:
+		history.Avg = math.Round(weighted/total*100) / 100
+	case len(history.Observations) > 0:
+		history.Avg = history.Current
+	}
+
+	return history
+}
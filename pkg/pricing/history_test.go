@@ -0,0 +1,60 @@
+package pricing
+
+import (
+	"testing"
+	"time"
+
+	"github.com/sosadtsia/bike-parts-finder/pkg/models"
+)
+
+func TestHistory(t *testing.T) {
+	from := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	to := from.AddDate(0, 0, 10)
+	observations := []models.PriceObservation{
+		{Price: 120, Currency: "USD", ObservedAt: from.AddDate(0, 0, -5)},
+		{Price: 100, Currency: "USD", ObservedAt: from.AddDate(0, 0, 5)},
+	}
+
+	history := History("part-1", observations, from, to)
+	if history.Min != 100 || history.Max != 120 || history.Current != 100 {
+		t.Errorf("min %v max %v current %v, want 100, 120 and 100", history.Min, history.Max, history.Current)
+	}
+	// Five days at each price
+	if history.Avg != 110 {
+		t.Errorf("avg %v, want 110", history.Avg)
+	}
+	if len(history.Observations) != 2 {
+		t.Errorf("got %d observations, want 2", len(history.Observations))
+	}
+}
+
+func TestHistorySkipsMissingPrices(t *testing.T) {
+	from := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	to := from.AddDate(0, 0, 10)
+	observations := []models.PriceObservation{
+		{Price: 120, Currency: "USD", ObservedAt: from},
+		{Price: 0, Currency: "USD", ObservedAt: from.AddDate(0, 0, 2)},
+		{Price: 90, Currency: "USD", ObservedAt: from.AddDate(0, 0, 5)},
+		{Price: -1, Currency: "USD", ObservedAt: from.AddDate(0, 0, 8)},
+	}
+
+	history := History("part-1", observations, from, to)
+	if history.Min != 90 || history.Current != 90 {
+		t.Errorf("min %v current %v, want 90", history.Min, history.Current)
+	}
+	// 120 stays in effect until the next real price
+	if history.Avg != 105 {
+		t.Errorf("avg %v, want 105", history.Avg)
+	}
+	if len(history.Observations) != 2 {
+		t.Errorf("got %d observations, want 2", len(history.Observations))
+	}
+}
+
+func TestHistoryWithoutObservations(t *testing.T) {
+	from := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	history := History("part-1", nil, from, from.AddDate(0, 0, 1))
+	if history.Observations == nil || history.Min != 0 || history.Avg != 0 {
+		t.Errorf("got %+v, want an empty history", history)
+	}
+}
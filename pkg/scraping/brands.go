@@ -0,0 +1,146 @@
+package scraping
+
+import (
+	_ "embed"
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+
+	"gopkg.in/yaml.v3"
+)
+
+//go:embed brands.yaml
+var brandsYAML []byte
+
+// defaultBrands is the brand dictionary used by all scrapers
+var defaultBrands = mustParseBrands(brandsYAML)
+
+// Brand is a brand with the other names retailers use for it
+type Brand struct {
+	Name    string   `yaml:"name" json:"name"`
+	Aliases []string `yaml:"aliases" json:"aliases"`
+}
+
+// BrandDictionary splits product names into brand and model and maps brand
+// spellings to a canonical name
+type BrandDictionary struct {
+	canonical map[string]string
+	prefixes  map[string]string
+	maxWords  int
+}
+
+// wordPattern matches the words of a product name
+var wordPattern = regexp.MustCompile(`\S+`)
+
+// NewBrandDictionary creates a brand dictionary from a list of brands
+func NewBrandDictionary(brands []Brand) *BrandDictionary {
+	d := &BrandDictionary{canonical: make(map[string]string), prefixes: make(map[string]string)}
+	for _, brand := range brands {
+		for _, name := range append([]string{brand.Name}, brand.Aliases...) {
+			key := brandKey(name)
+			if key == "" {
+				continue
+			}
+			d.canonical[key] = brand.Name
+
+			// Names without letters, such as "100%", read as quantities at
+			// the start of a product name and are only matched when given
+			// as the brand
+			if !hasLetter(key) {
+				continue
+			}
+			d.prefixes[key] = brand.Name
+			d.maxWords = max(d.maxWords, len(strings.Fields(name)))
+		}
+	}
+	return d
+}
+
+// ParseBrands reads a YAML list of brands
+func ParseBrands(data []byte) (*BrandDictionary, error) {
+	var brands []Brand
+	if err := yaml.Unmarshal(data, &brands); err != nil {
+		return nil, fmt.Errorf("parsing brands: %w", err)
+	}
+	return NewBrandDictionary(brands), nil
+}
+
+// mustParseBrands reads the embedded brand list
+func mustParseBrands(data []byte) *BrandDictionary {
+	d, err := ParseBrands(data)
+	if err != nil {
+		panic(err)
+	}
+	return d
+}
+
+// Canonical returns the canonical name of a brand
+func (d *BrandDictionary) Canonical(brand string) (string, bool) {
+	name, ok := d.canonical[brandKey(brand)]
+	return name, ok
+}
+
+// Split splits a product name into the canonical brand and the model,
+// matching the longest known brand or alias at the start of the name
+func (d *BrandDictionary) Split(name string) (brand, model string, ok bool) {
+	words := wordPattern.FindAllStringIndex(name, d.maxWords)
+	for n := len(words); n > 0; n-- {
+		end := words[n-1][1]
+		if brand, ok := d.prefixes[brandKey(name[:end])]; ok {
+			return brand, strings.TrimSpace(strings.TrimLeft(name[end:], " \t-–:|")), true
+		}
+	}
+	return "", strings.TrimSpace(name), false
+}
+
+// brandKey folds a brand name for matching, ignoring case, spaces and
+// punctuation
+func brandKey(name string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return unicode.ToLower(r)
+		}
+		return -1
+	}, name)
+}
+
+// hasLetter reports whether s contains a letter
+func hasLetter(s string) bool {
+	return strings.IndexFunc(s, unicode.IsLetter) >= 0
+}
+
+// splitBrand returns the canonical brand and the model of a product. brand
+// is the brand given by the retailer, if any; without one the brand is
+// taken from the start of the name, falling back to its first word unless
+// that has no letters.
+func splitBrand(brand, name string) (string, string) {
+	brand = strings.TrimSpace(brand)
+	name = strings.TrimSpace(name)
+
+	nameBrand, model, found := defaultBrands.Split(name)
+	if brand == "" {
+		if found {
+			return nameBrand, model
+		}
+		first, rest, _ := strings.Cut(name, " ")
+		if !hasLetter(first) {
+			return "", name
+		}
+		return first, strings.TrimSpace(rest)
+	}
+
+	canonical, known := defaultBrands.Canonical(brand)
+	if !known {
+		canonical = brand
+	}
+
+	// Drop the brand from the start of the name when it is repeated there
+	switch {
+	case found && nameBrand == canonical:
+		return canonical, model
+	case len(name) > len(brand) && strings.EqualFold(name[:len(brand)], brand):
+		return canonical, strings.TrimSpace(name[len(brand):])
+	}
+	return canonical, name
+}
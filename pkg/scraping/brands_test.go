@@ -0,0 +1,29 @@
+package scraping
+
+import "testing"
+
+func TestSplitBrand(t *testing.T) {
+	tests := []struct {
+		brand, name string
+		wantBrand   string
+		wantModel   string
+	}{
+		{"", "Shimano XT M8100 Cassette", "Shimano", "XT M8100 Cassette"},
+		{"", "RACE-FACE Turbine R Stem", "Race Face", "Turbine R Stem"},
+		{"", "Chris King - InSet 2 Headset", "Chris King", "InSet 2 Headset"},
+		{"", "Unknownco Widget", "Unknownco", "Widget"},
+		{"raceface", "Race Face Next R Bar", "Race Face", "Next R Bar"},
+		{"Hope", "Tech 4 V4 Brake", "Hope", "Tech 4 V4 Brake"},
+		// Percentages at the start of a name are not the brand 100%
+		{"", "100% Carbon Bar 800mm", "", "100% Carbon Bar 800mm"},
+		{"Race Face", "100% Carbon Bar 800mm", "Race Face", "100% Carbon Bar 800mm"},
+		{"100%", "Speedcraft Sunglasses", "100%", "Speedcraft Sunglasses"},
+		{"", "100 Percent Speedcraft Sunglasses", "100%", "Speedcraft Sunglasses"},
+	}
+	for _, tt := range tests {
+		brand, model := splitBrand(tt.brand, tt.name)
+		if brand != tt.wantBrand || model != tt.wantModel {
+			t.Errorf("splitBrand(%q, %q) = %q, %q; want %q, %q", tt.brand, tt.name, brand, model, tt.wantBrand, tt.wantModel)
+		}
+	}
+}
@@ -0,0 +1,28 @@
+package scraping
+
+import (
+	"strings"
+
+	"github.com/sosadtsia/bike-parts-finder/pkg/models"
+)
+
+// setBreadcrumbs records the retailer's category path on the part, skipping
+// the home page, and takes the retailer's category and subcategory from its
+// first and last entries until the path is mapped to the taxonomy
+func setBreadcrumbs(part *models.Part, crumbs []string) {
+	part.Breadcrumbs = nil
+	for _, crumb := range crumbs {
+		crumb = strings.Join(strings.Fields(crumb), " ")
+		if crumb != "" && !strings.EqualFold(crumb, "Home") {
+			part.Breadcrumbs = append(part.Breadcrumbs, crumb)
+		}
+	}
+
+	part.Category, part.SubCategory = "", ""
+	if n := len(part.Breadcrumbs); n > 0 {
+		part.Category = part.Breadcrumbs[0]
+		if n > 1 {
+			part.SubCategory = part.Breadcrumbs[n-1]
+		}
+	}
+}
@@ -0,0 +1,239 @@
+package scraping
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/gocolly/colly/v2"
+	"github.com/sosadtsia/bike-parts-finder/pkg/models"
+	"github.com/sosadtsia/bike-parts-finder/pkg/specs"
+)
+
+// Default limits applied when a scrape request does not set its own.
+// Depth is unlimited by default since the page budget already bounds the crawl.
+const (
+	DefaultMaxPages    = 500
+	DefaultMaxDuration = 10 * time.Minute
+)
+
+// crawl tracks the page, depth and time budget of a single scrape and the
+// pages that failed
+type crawl struct {
+	ctx      context.Context
+	maxPages int
+	maxDepth int
+	retry    RetryPolicy
+
+	mu        sync.Mutex
+	pages     int
+	truncated bool
+	attempts  map[string]int
+	errors    []models.ScrapeError
+}
+
+// newCrawl creates a crawl for the request. The returned context carries
+// the request's time budget and must be released with the cancel function.
+func newCrawl(ctx context.Context, req models.ScrapeRequest) (*crawl, context.CancelFunc) {
+	maxPages := req.MaxPages
+	if maxPages <= 0 {
+		maxPages = DefaultMaxPages
+	}
+	maxDuration := time.Duration(req.MaxDurationSeconds) * time.Second
+	if maxDuration <= 0 {
+		maxDuration = DefaultMaxDuration
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, maxDuration)
+	return &crawl{
+		ctx:      ctx,
+		maxPages: maxPages,
+		maxDepth: req.MaxDepth,
+		retry:    DefaultRetryPolicy,
+		attempts: make(map[string]int),
+	}, cancel
+}
+
+// truncate marks the crawl as stopped early
+func (cr *crawl) truncate() {
+	cr.mu.Lock()
+	cr.truncated = true
+	cr.mu.Unlock()
+}
+
+// acquirePage reserves a page from the budget, returning false once the
+// budget is spent or the crawl has been cancelled
+func (cr *crawl) acquirePage() bool {
+	cr.mu.Lock()
+	defer cr.mu.Unlock()
+
+	if cr.ctx.Err() != nil || cr.pages >= cr.maxPages {
+		cr.truncated = true
+		return false
+	}
+	cr.pages++
+	return true
+}
+
+// acquireRequest reserves a page from the budget for a colly request.
+// Retries of a page have already been counted, so they only stop once the
+// crawl is cancelled.
+func (cr *crawl) acquireRequest(url string) bool {
+	cr.mu.Lock()
+	retry := cr.attempts[url] > 0
+	cr.mu.Unlock()
+
+	if !retry {
+		return cr.acquirePage()
+	}
+	if cr.ctx.Err() != nil {
+		cr.truncate()
+		return false
+	}
+	return true
+}
+
+// newCollector creates a colly collector bound to the crawl's context and
+// limits that sends its requests through the politeness policy
+func (cr *crawl) newCollector(options ...colly.CollectorOption) *colly.Collector {
+	options = append([]colly.CollectorOption{
+		colly.StdlibContext(cr.ctx),
+		colly.MaxDepth(max(cr.maxDepth, 0)),
+		colly.UserAgent(currentPoliteness().UserAgent),
+	}, options...)
+	c := colly.NewCollector(options...)
+
+	// robots.txt, crawl delays and Retry-After are handled by the shared
+	// politeness policy rather than per collector
+	c.IgnoreRobotsTxt = true
+	c.WithTransport(politeTransport{})
+
+	// Stop issuing requests once the budget is spent or the crawl is cancelled
+	c.OnRequest(func(r *colly.Request) {
+		if !cr.acquireRequest(r.URL.String()) {
+			r.Abort()
+		}
+	})
+
+	// Retry transient failures and record pages that could not be scraped
+	c.OnError(func(r *colly.Response, err error) {
+		delay, retry := cr.fail(r.Request.URL.String(), r.StatusCode, err)
+		if retry && cr.sleep(delay) == nil {
+			r.Request.Retry()
+		}
+	})
+
+	return c
+}
+
+// start visits the first page of the crawl. Failures of the page itself
+// are recorded in the crawl's errors, so only errors that prevented the
+// request from being made are returned.
+func (cr *crawl) start(c *colly.Collector, url string) error {
+	err := c.Visit(url)
+
+	// The first page is the first request, so any recorded attempt means
+	// it was made and its failure has been handled
+	cr.mu.Lock()
+	attempted := len(cr.attempts) > 0
+	cr.mu.Unlock()
+
+	if err != nil && !attempted && cr.ctx.Err() == nil {
+		return fmt.Errorf("error starting the scraper: %w", err)
+	}
+	return nil
+}
+
+// do runs fetch for url, retrying transient failures. The error of the
+// last attempt is returned once the page has been recorded as failed.
+func (cr *crawl) do(url string, fetch func() error) error {
+	for {
+		err := fetch()
+		if err == nil {
+			return nil
+		}
+		delay, retry := cr.fail(url, 0, err)
+		if !retry {
+			return err
+		}
+		if err := cr.sleep(delay); err != nil {
+			return err
+		}
+	}
+}
+
+// fail records a failed attempt at url and reports whether and after what
+// delay it should be retried. Failures caused by the crawl being cancelled
+// mark it as truncated instead of being recorded.
+func (cr *crawl) fail(url string, statusCode int, err error) (time.Duration, bool) {
+	cr.mu.Lock()
+	defer cr.mu.Unlock()
+
+	cr.attempts[url]++
+	attempts := cr.attempts[url]
+
+	if cr.ctx.Err() != nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		cr.truncated = true
+		return 0, false
+	}
+
+	kind := classifyError(statusCode, err)
+	if isTransient(kind) && attempts < cr.retry.MaxAttempts {
+		return cr.retry.backoff(attempts), true
+	}
+
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) {
+		statusCode = statusErr.StatusCode
+	}
+	cr.errors = append(cr.errors, models.ScrapeError{
+		URL:        url,
+		Kind:       kind,
+		StatusCode: statusCode,
+		Message:    err.Error(),
+		Attempts:   attempts,
+	})
+	return 0, false
+}
+
+// sleep waits for d or until the crawl is cancelled
+func (cr *crawl) sleep(d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-cr.ctx.Done():
+		return cr.ctx.Err()
+	}
+}
+
+// result builds the scrape result for the request from the parts found
+func (cr *crawl) result(req models.ScrapeRequest, parts []models.Part) models.ScrapeResult {
+	cr.mu.Lock()
+	defer cr.mu.Unlock()
+
+	// Parse spec values into typed attributes for every scraper alike
+	for i := range parts {
+		parts[i].Specs = specs.NormalizeAll(parts[i].Specs)
+	}
+
+	return models.ScrapeResult{
+		RequestID: req.ID,
+		URL:       req.URL,
+		Parts:     parts,
+		Truncated: cr.truncated,
+		Errors:    cr.errors,
+		Timestamp: time.Now(),
+	}
+}
+
+// visit follows a link found on the page of the given request, recording
+// when the depth limit prevents it from being followed
+func (cr *crawl) visit(r *colly.Request, url string) {
+	if err := r.Visit(url); errors.Is(err, colly.ErrMaxDepth) {
+		cr.truncate()
+	}
+}
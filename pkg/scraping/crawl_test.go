@@ -0,0 +1,63 @@
+package scraping
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/gocolly/colly/v2"
+	"github.com/sosadtsia/bike-parts-finder/pkg/models"
+)
+
+func TestCrawlRetriesDoNotSpendBudget(t *testing.T) {
+	politeness := NewPoliteness()
+	politeness.Delay = 0
+	SetPoliteness(politeness)
+	t.Cleanup(func() { SetPoliteness(NewPoliteness()) })
+
+	var categoryRequests atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/category":
+			// Fail the first two attempts so the page is retried
+			if categoryRequests.Add(1) <= 2 {
+				http.Error(w, "unavailable", http.StatusServiceUnavailable)
+				return
+			}
+			w.Write([]byte(`<a href="/product">Product</a>`))
+		case "/product":
+			w.Write([]byte(`<h1>Product</h1>`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	req := models.ScrapeRequest{URL: srv.URL + "/category", MaxPages: 2}
+	cr, cancel := newCrawl(context.Background(), req)
+	defer cancel()
+	cr.retry = RetryPolicy{MaxAttempts: 3}
+
+	var visited []string
+	c := cr.newCollector()
+	c.OnHTML("a[href]", func(e *colly.HTMLElement) {
+		cr.visit(e.Request, e.Request.AbsoluteURL(e.Attr("href")))
+	})
+	c.OnResponse(func(r *colly.Response) {
+		visited = append(visited, r.Request.URL.Path)
+	})
+	if err := cr.start(c, req.URL); err != nil {
+		t.Fatal(err)
+	}
+
+	// Both pages fit the budget of two however often the first was retried
+	result := cr.result(req, nil)
+	if len(visited) != 2 || result.Truncated || len(result.Errors) != 0 {
+		t.Errorf("visited %v, truncated %v, errors %+v; want both pages without truncation", visited, result.Truncated, result.Errors)
+	}
+	if got := categoryRequests.Load(); got != 3 {
+		t.Errorf("got %d category requests, want 3", got)
+	}
+}
@@ -0,0 +1,100 @@
+package scraping
+
+import (
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"net/http"
+	"time"
+
+	"github.com/sosadtsia/bike-parts-finder/pkg/models"
+)
+
+// StatusError is returned for HTTP responses with an unexpected status
+type StatusError struct {
+	URL        string
+	StatusCode int
+}
+
+// Error implements the error interface
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("%s: unexpected status %d %s", e.URL, e.StatusCode, http.StatusText(e.StatusCode))
+}
+
+// ParseError is returned when a page was fetched but could not be parsed
+type ParseError struct {
+	URL string
+	Err error
+}
+
+// Error implements the error interface
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("parsing %s: %v", e.URL, e.Err)
+}
+
+// Unwrap returns the underlying parse error
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
+
+// RetryPolicy configures retries of transient scrape failures
+type RetryPolicy struct {
+	// MaxAttempts is the number of attempts per page, including the first
+	MaxAttempts int
+	// BaseDelay is the backoff before the first retry; it doubles with
+	// every further attempt up to MaxDelay
+	BaseDelay time.Duration
+	// MaxDelay caps the backoff between attempts
+	MaxDelay time.Duration
+}
+
+// DefaultRetryPolicy retries transient failures twice
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts: 3,
+	BaseDelay:   500 * time.Millisecond,
+	MaxDelay:    10 * time.Second,
+}
+
+// backoff returns a jittered delay before the given retry, drawn uniformly
+// from [d/2, d) where d grows exponentially with the attempt
+func (p RetryPolicy) backoff(attempt int) time.Duration {
+	d := p.BaseDelay << min(max(attempt-1, 0), 16)
+	if d <= 0 || d > p.MaxDelay {
+		d = p.MaxDelay
+	}
+	if d <= 0 {
+		return 0
+	}
+	return d/2 + rand.N(d/2+1)
+}
+
+// classifyError returns the kind of a scrape failure given the response
+// status (zero if there was no response) and error
+func classifyError(statusCode int, err error) string {
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) {
+		statusCode = statusErr.StatusCode
+	}
+	var parseErr *ParseError
+
+	switch {
+	case errors.Is(err, ErrDisallowedByRobots):
+		return models.ScrapeErrorBlocked
+	case statusCode == http.StatusForbidden || statusCode == http.StatusTooManyRequests:
+		return models.ScrapeErrorBlocked
+	case statusCode >= 500:
+		return models.ScrapeErrorServer
+	case statusCode >= 400:
+		return models.ScrapeErrorClient
+	case errors.As(err, &parseErr), statusCode >= 200 && statusCode < 300:
+		return models.ScrapeErrorParse
+	default:
+		return models.ScrapeErrorNetwork
+	}
+}
+
+// isTransient reports whether a failure of the given kind may succeed if
+// the request is retried
+func isTransient(kind string) bool {
+	return kind == models.ScrapeErrorNetwork || kind == models.ScrapeErrorServer
+}
@@ -0,0 +1,167 @@
+package scraping
+
+import (
+	"net"
+	neturl "net/url"
+	"sort"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/sosadtsia/bike-parts-finder/pkg/models"
+)
+
+// partNamespace is the UUID namespace part IDs are derived in
+var partNamespace = uuid.MustParse("6f1c0f57-3b0e-4a53-9d0c-3f8f2a5b7c21")
+
+// trackingParams are query parameters that do not identify a product and
+// are removed from canonical URLs
+var trackingParams = map[string]bool{
+	"gclid":   true,
+	"gbraid":  true,
+	"wbraid":  true,
+	"dclid":   true,
+	"fbclid":  true,
+	"msclkid": true,
+	"yclid":   true,
+	"mc_cid":  true,
+	"mc_eid":  true,
+	"_ga":     true,
+	"_gl":     true,
+	"srsltid": true,
+	"ref":     true,
+	"ref_src": true,
+	"affid":   true,
+	"avad":    true,
+	"cjevent": true,
+	"irclid":  true,
+}
+
+// trackingPrefixes are prefixes of tracking query parameters
+var trackingPrefixes = []string{"utm_", "pk_", "mtm_", "hsa_"}
+
+// CanonicalURL normalises a product URL so that links to the same product
+// compare equal: the scheme and host are lowercased, default ports,
+// fragments, tracking parameters and trailing slashes are removed and the
+// remaining query parameters are sorted. URLs that cannot be parsed are
+// returned unchanged.
+func CanonicalURL(raw string) string {
+	u, err := neturl.Parse(strings.TrimSpace(raw))
+	if err != nil || u.Host == "" {
+		return raw
+	}
+
+	u.Scheme = strings.ToLower(u.Scheme)
+	u.Host = strings.ToLower(u.Host)
+	if host, port, err := net.SplitHostPort(u.Host); err == nil &&
+		((u.Scheme == "http" && port == "80") || (u.Scheme == "https" && port == "443")) {
+		u.Host = host
+	}
+	u.Fragment = ""
+	u.RawFragment = ""
+	u.User = nil
+
+	if len(u.Path) > 1 {
+		u.Path = strings.TrimRight(u.Path, "/")
+		u.RawPath = strings.TrimRight(u.RawPath, "/")
+	}
+	if u.Path == "/" {
+		u.Path = ""
+	}
+
+	query := u.Query()
+	for key, values := range query {
+		if isTrackingParam(key) {
+			query.Del(key)
+			continue
+		}
+		sort.Strings(values)
+	}
+	// Encode sorts the parameters by key
+	u.RawQuery = query.Encode()
+
+	return u.String()
+}
+
+// isTrackingParam reports whether a query parameter only tracks the visit
+func isTrackingParam(key string) bool {
+	key = strings.ToLower(key)
+	if trackingParams[key] {
+		return true
+	}
+	for _, prefix := range trackingPrefixes {
+		if strings.HasPrefix(key, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// PartID returns a stable ID for a part so that re-scraping a product
+// updates the stored part instead of creating a new one. It is derived
+// from the source and the retailer's SKU when known, and from the
+// canonical product URL otherwise.
+func PartID(source, productURL, sku string) string {
+	key := strings.ToLower(strings.TrimSpace(source)) + "|"
+	if sku = strings.TrimSpace(sku); sku != "" {
+		key += "sku:" + strings.ToLower(sku)
+	} else {
+		key += "url:" + CanonicalURL(productURL)
+	}
+	return uuid.NewSHA1(partNamespace, []byte(key)).String()
+}
+
+// identifierSpecs maps lowercased spec names to the identifier they hold
+var identifierSpecs = map[string]string{
+	"gtin":                     "gtin",
+	"upc":                      "gtin",
+	"upc code":                 "gtin",
+	"ean":                      "gtin",
+	"ean code":                 "gtin",
+	"barcode":                  "gtin",
+	"mpn":                      "mpn",
+	"manufacturer part number": "mpn",
+	"manufacturer part #":      "mpn",
+	"mfr part number":          "mpn",
+	"mfr part #":               "mpn",
+	"part number":              "mpn",
+	"sku":                      "sku",
+	"item #":                   "sku",
+	"item number":              "sku",
+	"product code":             "sku",
+}
+
+// identifyPart fills identifiers missing from the part from its specs,
+// drops invalid GTINs, canonicalises the URLs and assigns the stable ID
+func identifyPart(part *models.Part) {
+	for _, spec := range part.Specs {
+		value := strings.TrimSpace(spec.Value)
+		switch identifierSpecs[strings.ToLower(strings.TrimSpace(strings.TrimSuffix(spec.Name, ":")))] {
+		case "gtin":
+			if _, ok := models.NormalizeGTIN(part.GTIN); !ok {
+				part.GTIN = value
+			}
+		case "mpn":
+			if part.MPN == "" {
+				part.MPN = value
+			}
+		case "sku":
+			if part.SKU == "" {
+				part.SKU = value
+			}
+		}
+	}
+
+	part.GTIN, _ = models.NormalizeGTIN(part.GTIN)
+	for i := range part.Variants {
+		part.Variants[i].GTIN, _ = models.NormalizeGTIN(part.Variants[i].GTIN)
+		part.Variants[i].SKU = strings.TrimSpace(part.Variants[i].SKU)
+		if part.Variants[i].URL != "" {
+			part.Variants[i].URL = CanonicalURL(part.Variants[i].URL)
+		}
+	}
+	part.MPN = strings.TrimSpace(part.MPN)
+	part.SKU = strings.TrimSpace(part.SKU)
+
+	part.URL = CanonicalURL(part.URL)
+	part.ID = PartID(part.Source, part.URL, part.SKU)
+}
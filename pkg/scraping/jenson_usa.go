@@ -1,41 +1,79 @@
 package scraping
 
 import (
-	"fmt"
-	"strconv"
+	"context"
+	"encoding/json"
+	neturl "net/url"
 	"strings"
 	"time"
 
+	"github.com/PuerkitoBio/goquery"
 	"github.com/gocolly/colly/v2"
-	"github.com/google/uuid"
 	"github.com/sosadtsia/bike-parts-finder/pkg/models"
 )
 
+func init() {
+	register(func() Scraper { return NewJensonUSAScraper() })
+}
+
 // JensonUSAScraper is a scraper for JensonUSA website
 type JensonUSAScraper struct {
 	baseURL string
+	domains []string
 }
 
 // NewJensonUSAScraper creates a new JensonUSA scraper
 func NewJensonUSAScraper() *JensonUSAScraper {
 	return &JensonUSAScraper{
 		baseURL: "https://www.jensonusa.com",
+		domains: []string{"www.jensonusa.com", "jensonusa.com"},
+	}
+}
+
+// NewJensonUSAScraperWithBaseURL creates a JensonUSA scraper that crawls
+// baseURL instead of the live site, e.g. a fixture server
+func NewJensonUSAScraperWithBaseURL(baseURL string) *JensonUSAScraper {
+	baseURL = strings.TrimRight(baseURL, "/")
+	var domains []string
+	if u, err := neturl.Parse(baseURL); err == nil {
+		domains = []string{u.Hostname()}
+	}
+	return &JensonUSAScraper{
+		baseURL: baseURL,
+		domains: domains,
 	}
 }
 
+// Name returns the source name for JensonUSA
+func (s *JensonUSAScraper) Name() string {
+	return "JensonUSA"
+}
+
 // CanHandle checks if this scraper can handle the given URL
 func (s *JensonUSAScraper) CanHandle(url string) bool {
-	return strings.Contains(url, "jensonusa.com")
+	u, err := neturl.Parse(url)
+	if err != nil {
+		return false
+	}
+	for _, domain := range s.domains {
+		if u.Hostname() == domain {
+			return true
+		}
+	}
+	return false
 }
 
 // Scrape scrapes bike parts from JensonUSA
-func (s *JensonUSAScraper) Scrape(url string) ([]models.Part, error) {
+func (s *JensonUSAScraper) Scrape(ctx context.Context, req models.ScrapeRequest) (models.ScrapeResult, error) {
 	var parts []models.Part
+	url := req.URL
+
+	cr, cancel := newCrawl(ctx, req)
+	defer cancel()
 
 	// Initialize the collector
-	c := colly.NewCollector(
-		colly.AllowedDomains("www.jensonusa.com", "jensonusa.com"),
-		colly.UserAgent("Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/91.0.4472.124 Safari/537.36"),
+	c := cr.newCollector(
+		colly.AllowedDomains(s.domains...),
 	)
 
 	// Category page scraper
@@ -48,7 +86,7 @@ func (s *JensonUSAScraper) Scrape(url string) ([]models.Part, error) {
 					productURL = s.baseURL + productURL
 				}
 				// Visit the product page
-				c.Visit(productURL)
+				cr.visit(e.Request, productURL)
 			}
 		})
 
@@ -56,7 +94,7 @@ func (s *JensonUSAScraper) Scrape(url string) ([]models.Part, error) {
 		c.OnHTML("a.pagination__link", func(e *colly.HTMLElement) {
 			nextURL := e.Attr("href")
 			if nextURL != "" && !strings.Contains(nextURL, "page=1") { // Avoid infinite loop
-				c.Visit(s.baseURL + nextURL)
+				cr.visit(e.Request, s.baseURL+nextURL)
 			}
 		})
 	}
@@ -65,34 +103,16 @@ func (s *JensonUSAScraper) Scrape(url string) ([]models.Part, error) {
 	c.OnHTML("div.product-details", func(e *colly.HTMLElement) {
 		var part models.Part
 
-		// Generate a unique ID for the part
-		part.ID = uuid.New().String()
-
 		// Get the product URL
 		part.URL = e.Request.URL.String()
-		part.Source = "JensonUSA"
+		part.Source = s.Name()
 
 		// Get the product name, which usually contains brand and model
 		productName := e.ChildText("h1.product-details__name")
-		nameParts := strings.SplitN(productName, " ", 2)
-		if len(nameParts) > 0 {
-			part.Brand = nameParts[0]
-		}
-		if len(nameParts) > 1 {
-			part.Model = nameParts[1]
-		}
+		part.Brand, part.Model = splitBrand("", productName)
 
 		// Get the category from breadcrumbs
-		e.ForEach("ol.breadcrumb li", func(_ int, el *colly.HTMLElement) {
-			category := strings.TrimSpace(el.Text)
-			if category != "Home" && category != "" {
-				if part.Category == "" {
-					part.Category = category
-				} else {
-					part.SubCategory = category
-				}
-			}
-		})
+		setBreadcrumbs(&part, e.ChildTexts("ol.breadcrumb li"))
 
 		// Get the price
 		priceText := e.ChildText("span.product-details__price--sale")
@@ -100,20 +120,15 @@ func (s *JensonUSAScraper) Scrape(url string) ([]models.Part, error) {
 			priceText = e.ChildText("span.product-details__price")
 		}
 
-		// Clean up price and convert to float
-		priceText = strings.TrimSpace(strings.ReplaceAll(priceText, "$", ""))
-		price, err := strconv.ParseFloat(priceText, 64)
-		if err == nil {
-			part.Price = price
-			part.Currency = "USD"
+		// Parse the price, which is in US dollars unless it says otherwise
+		if price, ok := ParsePrice(priceText, "USD"); ok {
+			part.Price = price.Amount
+			part.Currency = price.Currency
 		}
 
 		// Get MSRP if available
-		msrpText := e.ChildText("span.product-details__price--msrp")
-		msrpText = strings.TrimSpace(strings.ReplaceAll(msrpText, "$", ""))
-		msrp, err := strconv.ParseFloat(msrpText, 64)
-		if err == nil {
-			part.MSRP = msrp
+		if msrp, ok := ParsePrice(e.ChildText("span.product-details__price--msrp"), "USD"); ok {
+			part.MSRP = msrp.Amount
 
 			// Calculate discount
 			if part.MSRP > 0 {
@@ -125,6 +140,26 @@ func (s *JensonUSAScraper) Scrape(url string) ([]models.Part, error) {
 		stockText := e.ChildText("div.product-details__stock")
 		part.InStock = !strings.Contains(strings.ToLower(stockText), "out of stock")
 
+		// Get variants, e.g. colours, each with its own SKU, price and stock
+		e.ForEach("select.product-details__variants", func(_ int, sel *colly.HTMLElement) {
+			option := sel.Attr("data-option")
+			if option == "" {
+				option = "Option"
+			}
+			sel.ForEach("option[data-sku]", func(_ int, el *colly.HTMLElement) {
+				variant := models.Variant{
+					Attributes: map[string]string{option: strings.TrimSpace(el.Text)},
+					SKU:        el.Attr("data-sku"),
+					InStock:    el.Attr("data-stock") != "out",
+				}
+				if price, ok := ParsePrice(el.Attr("data-price"), "USD"); ok {
+					variant.Price = price.Amount
+				}
+				part.Variants = append(part.Variants, variant)
+			})
+		})
+		summarizeVariants(&part)
+
 		// Get the description
 		part.Description = e.ChildText("div.product-details__description")
 
@@ -152,6 +187,39 @@ func (s *JensonUSAScraper) Scrape(url string) ([]models.Part, error) {
 			}
 		})
 
+		// Get identifiers from data attributes, then from the page's JSON-LD;
+		// identifyPart falls back to the specifications table
+		part.SKU = e.Attr("data-sku")
+		part.MPN = e.Attr("data-mpn")
+		part.GTIN = e.Attr("data-gtin")
+		if part.GTIN == "" {
+			part.GTIN = e.Attr("data-upc")
+		}
+		e.DOM.Closest("html").Find(`script[type="application/ld+json"]`).Each(func(_ int, script *goquery.Selection) {
+			var doc any
+			if err := json.Unmarshal([]byte(script.Text()), &doc); err != nil {
+				return
+			}
+			for _, node := range ldNodes(doc) {
+				if !ldIsType(node, "Product") {
+					continue
+				}
+				product := parseLDProduct(node)
+				if part.SKU == "" {
+					part.SKU = product.SKU
+				}
+				if part.MPN == "" {
+					part.MPN = product.MPN
+				}
+				if part.GTIN == "" {
+					part.GTIN = product.GTIN
+				}
+			}
+		})
+
+		// Derive a stable ID so re-scrapes update the same part
+		identifyPart(&part)
+
 		// Set timestamps
 		now := time.Now()
 		part.CreatedAt = now
@@ -161,19 +229,13 @@ func (s *JensonUSAScraper) Scrape(url string) ([]models.Part, error) {
 		parts = append(parts, part)
 	})
 
-	// Handle errors
-	c.OnError(func(r *colly.Response, err error) {
-		fmt.Printf("Request URL: %s failed with error: %s\n", r.Request.URL, err)
-	})
-
 	// Start the scraping
-	err := c.Visit(url)
-	if err != nil {
-		return nil, fmt.Errorf("error starting the scraper: %w", err)
+	if err := cr.start(c, url); err != nil {
+		return models.ScrapeResult{}, err
 	}
 
 	// Wait for scraping to finish
 	c.Wait()
 
-	return parts, nil
+	return cr.result(req, parts), nil
 }
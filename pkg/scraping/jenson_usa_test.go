@@ -0,0 +1,80 @@
+package scraping_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/sosadtsia/bike-parts-finder/pkg/models"
+	"github.com/sosadtsia/bike-parts-finder/pkg/scraping"
+	"github.com/sosadtsia/bike-parts-finder/pkg/scraping/scrapetest"
+)
+
+func TestJensonUSAFixtures(t *testing.T) {
+	scrapetest.Run(t, scrapetest.Case{
+		New:      func(baseURL string) scraping.Scraper { return scraping.NewJensonUSAScraperWithBaseURL(baseURL) },
+		Fixtures: "testdata/jensonusa",
+		Path:     "/categories/brakes",
+		Golden:   "testdata/jensonusa/brakes.golden.json",
+	})
+}
+
+func TestJensonUSAProductErrors(t *testing.T) {
+	disablePoliteness(t)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/categories/chains":
+			w.Write([]byte(`
+				<div class="product-tile"><a class="product-tile__image-link" href="/products/kmc-x12">KMC X12</a></div>
+				<div class="product-tile"><a class="product-tile__image-link" href="/products/missing">Missing</a></div>
+			`))
+		case "/products/kmc-x12":
+			w.Write([]byte(`<div class="product-details"><h1 class="product-details__name">KMC X12 Chain</h1></div>`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	result, err := scraping.NewJensonUSAScraperWithBaseURL(srv.URL).Scrape(context.Background(), models.ScrapeRequest{URL: srv.URL + "/categories/chains"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A missing product page is reported without failing the scrape
+	if len(result.Parts) != 1 || result.Parts[0].Brand != "KMC" {
+		t.Errorf("got parts %+v, want the KMC chain", result.Parts)
+	}
+	if len(result.Errors) != 1 || result.Errors[0].Kind != "client" || result.Errors[0].StatusCode != http.StatusNotFound {
+		t.Errorf("got errors %+v, want a 404 client error", result.Errors)
+	}
+}
+
+// BenchmarkJensonUSAScrape measures crawling and parsing the recorded
+// brake category and its product pages
+func BenchmarkJensonUSAScrape(b *testing.B) {
+	disablePoliteness(b)
+	srv := scrapetest.NewServer(b, "testdata/jensonusa")
+	scraper := scraping.NewJensonUSAScraperWithBaseURL(srv.URL)
+
+	for b.Loop() {
+		result, err := scraper.Scrape(context.Background(), models.ScrapeRequest{URL: srv.URL + "/categories/brakes"})
+		if err != nil {
+			b.Fatal(err)
+		}
+		if len(result.Parts) == 0 {
+			b.Fatal("no parts scraped")
+		}
+	}
+}
+
+// disablePoliteness turns off crawl delays for the duration of a test
+func disablePoliteness(t testing.TB) {
+	t.Helper()
+	politeness := scraping.NewPoliteness()
+	politeness.Delay = 0
+	scraping.SetPoliteness(politeness)
+	t.Cleanup(func() { scraping.SetPoliteness(scraping.NewPoliteness()) })
+}
@@ -0,0 +1,415 @@
+package scraping
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/temoto/robotstxt"
+)
+
+// DefaultUserAgent identifies the scraper to retailers
+const DefaultUserAgent = "BikePartsFinderBot/1.0 (+https://github.com/sosadtsia/bike-parts-finder)"
+
+const (
+	// robotsTTL is how long a fetched robots.txt is trusted
+	robotsTTL = 24 * time.Hour
+	// robotsErrorTTL is how long a robots.txt answered with a server error
+	// (treated as a full disallow) is cached before it is fetched again
+	robotsErrorTTL = 10 * time.Minute
+	// robotsUnreachableTTL is how long a robots.txt that could not be
+	// fetched at all (treated as a full allow) is cached before it is
+	// fetched again
+	robotsUnreachableTTL = 5 * time.Minute
+)
+
+// ErrDisallowedByRobots is returned for requests that robots.txt forbids
+var ErrDisallowedByRobots = errors.New("disallowed by robots.txt")
+
+// DomainPolicy overrides the crawl delay and concurrency for a domain
+type DomainPolicy struct {
+	Delay       time.Duration
+	Parallelism int
+}
+
+// Politeness is an http.RoundTripper shared by all scrapers that enforces
+// robots.txt, spaces out and limits concurrent requests per domain and
+// backs off when a retailer answers 429 or 503 with Retry-After
+type Politeness struct {
+	// UserAgent is sent with every request and matched against robots.txt
+	UserAgent string
+	// Delay is the minimum time between requests to the same domain
+	Delay time.Duration
+	// Parallelism is the maximum number of concurrent requests per domain
+	Parallelism int
+	// Domains overrides Delay and Parallelism for specific domains
+	Domains map[string]DomainPolicy
+	// MaxRetryAfter is the longest Retry-After a request is retried after;
+	// longer waits fail the request but still hold back later requests
+	MaxRetryAfter time.Duration
+
+	base http.RoundTripper
+
+	mu      sync.Mutex
+	domains map[string]*domainState
+	robots  map[string]robotsEntry
+}
+
+// domainState tracks the request schedule of a single domain
+type domainState struct {
+	slots chan struct{}
+
+	mu    sync.Mutex
+	delay time.Duration
+	next  time.Time
+}
+
+// robotsEntry is a cached robots.txt
+type robotsEntry struct {
+	data    *robotstxt.RobotsData
+	expires time.Time
+}
+
+// NewPoliteness creates a politeness policy with one request per second
+// and domain
+func NewPoliteness() *Politeness {
+	return &Politeness{
+		UserAgent:     DefaultUserAgent,
+		Delay:         time.Second,
+		Parallelism:   1,
+		Domains:       make(map[string]DomainPolicy),
+		MaxRetryAfter: 2 * time.Minute,
+		base:          http.DefaultTransport,
+		domains:       make(map[string]*domainState),
+		robots:        make(map[string]robotsEntry),
+	}
+}
+
+// NewPolitenessFromEnv creates a politeness policy configured from
+// SCRAPER_USER_AGENT, SCRAPER_CRAWL_DELAY, SCRAPER_PARALLELISM and
+// SCRAPER_DOMAIN_POLICIES, a comma-separated list of domain=delay[:parallelism]
+// entries such as "jensonusa.com=2s,shop.example.com=500ms:2"
+func NewPolitenessFromEnv() (*Politeness, error) {
+	p := NewPoliteness()
+
+	if ua := os.Getenv("SCRAPER_USER_AGENT"); ua != "" {
+		p.UserAgent = ua
+	}
+	if v := os.Getenv("SCRAPER_CRAWL_DELAY"); v != "" {
+		delay, err := time.ParseDuration(v)
+		if err != nil {
+			return nil, fmt.Errorf("parsing SCRAPER_CRAWL_DELAY: %w", err)
+		}
+		p.Delay = delay
+	}
+	if v := os.Getenv("SCRAPER_PARALLELISM"); v != "" {
+		parallelism, err := strconv.Atoi(v)
+		if err != nil {
+			return nil, fmt.Errorf("parsing SCRAPER_PARALLELISM: %w", err)
+		}
+		p.Parallelism = parallelism
+	}
+
+	for _, entry := range strings.Split(os.Getenv("SCRAPER_DOMAIN_POLICIES"), ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		domain, spec, ok := strings.Cut(entry, "=")
+		if !ok {
+			return nil, fmt.Errorf("parsing SCRAPER_DOMAIN_POLICIES entry %q: missing '='", entry)
+		}
+		delayText, parallelismText, _ := strings.Cut(spec, ":")
+
+		var policy DomainPolicy
+		delay, err := time.ParseDuration(delayText)
+		if err != nil {
+			return nil, fmt.Errorf("parsing delay for %s: %w", domain, err)
+		}
+		policy.Delay = delay
+		if parallelismText != "" {
+			if policy.Parallelism, err = strconv.Atoi(parallelismText); err != nil {
+				return nil, fmt.Errorf("parsing parallelism for %s: %w", domain, err)
+			}
+		}
+		p.Domains[strings.ToLower(strings.TrimSpace(domain))] = policy
+	}
+
+	return p, nil
+}
+
+// defaultPoliteness is the policy applied by all scrapers
+var defaultPoliteness atomic.Pointer[Politeness]
+
+func init() {
+	defaultPoliteness.Store(NewPoliteness())
+}
+
+// SetPoliteness replaces the politeness policy applied by all scrapers
+func SetPoliteness(p *Politeness) {
+	defaultPoliteness.Store(p)
+}
+
+// currentPoliteness returns the politeness policy applied by all scrapers
+func currentPoliteness() *Politeness {
+	return defaultPoliteness.Load()
+}
+
+// politeTransport sends requests through the current politeness policy, so
+// scrapers pick up a policy set after they were created
+type politeTransport struct{}
+
+// RoundTrip sends the request through the current politeness policy
+func (politeTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	return currentPoliteness().RoundTrip(req)
+}
+
+// RoundTrip sends a request once robots.txt allows it and the domain's
+// delay and concurrency limits permit
+func (p *Politeness) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+
+	if req.URL.Path != "/robots.txt" {
+		allowed, err := p.Allowed(ctx, req)
+		if err != nil {
+			return nil, err
+		}
+		if !allowed {
+			return nil, fmt.Errorf("%s: %w", req.URL, ErrDisallowedByRobots)
+		}
+	}
+
+	if req.Header.Get("User-Agent") == "" {
+		req = req.Clone(ctx)
+		req.Header.Set("User-Agent", p.UserAgent)
+	}
+
+	d := p.domain(req.URL.Hostname())
+
+	// Limit concurrent requests to the domain
+	select {
+	case d.slots <- struct{}{}:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	defer func() { <-d.slots }()
+
+	for retried := false; ; retried = true {
+		if err := d.wait(ctx); err != nil {
+			return nil, err
+		}
+
+		resp, err := p.base.RoundTrip(req)
+		if err != nil {
+			return nil, err
+		}
+		if resp.StatusCode != http.StatusTooManyRequests && resp.StatusCode != http.StatusServiceUnavailable {
+			return resp, nil
+		}
+
+		// Hold back every request to the domain until the retailer is ready
+		after, ok := parseRetryAfter(resp.Header.Get("Retry-After"), time.Now())
+		if !ok {
+			return resp, nil
+		}
+		d.holdUntil(time.Now().Add(after))
+
+		// Retry once if the wait is reasonable and the request can be resent
+		if retried || after > p.MaxRetryAfter || (req.Body != nil && req.GetBody == nil) {
+			return resp, nil
+		}
+		io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+		if req.GetBody != nil {
+			if req.Body, err = req.GetBody(); err != nil {
+				return nil, err
+			}
+		}
+	}
+}
+
+// Allowed reports whether robots.txt of the request's host permits it,
+// fetching and caching robots.txt as needed
+func (p *Politeness) Allowed(ctx context.Context, req *http.Request) (bool, error) {
+	robots, err := p.robotsFor(ctx, req.URL.Scheme, req.URL.Host)
+	if err != nil {
+		return false, err
+	}
+
+	group := robots.FindGroup(p.UserAgent)
+	if group.CrawlDelay > 0 {
+		p.domain(req.URL.Hostname()).raiseDelay(group.CrawlDelay)
+	}
+
+	path := req.URL.EscapedPath()
+	if req.URL.RawQuery != "" {
+		path += "?" + req.URL.RawQuery
+	}
+	return group.Test(path), nil
+}
+
+// robotsFor returns the cached robots.txt of a host, fetching it if needed
+func (p *Politeness) robotsFor(ctx context.Context, scheme, host string) (*robotstxt.RobotsData, error) {
+	key := scheme + "://" + host
+
+	p.mu.Lock()
+	entry, ok := p.robots[key]
+	p.mu.Unlock()
+	if ok && time.Now().Before(entry.expires) {
+		return entry.data, nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, key+"/robots.txt", nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", p.UserAgent)
+
+	// Follow redirects, e.g. from http to https or to the www host
+	client := &http.Client{Transport: p.base}
+	resp, err := client.Do(req)
+	if err == nil {
+		defer resp.Body.Close()
+	}
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+
+	var data *robotstxt.RobotsData
+	ttl := robotsTTL
+	if err != nil {
+		// An unreachable host would fail the request anyway, so allow it
+		// rather than fetching robots.txt again for every request
+		data, _ = robotstxt.FromStatusAndBytes(http.StatusNotFound, nil)
+		ttl = robotsUnreachableTTL
+	} else {
+		data, err = robotstxt.FromResponse(resp)
+		if err != nil {
+			return nil, fmt.Errorf("parsing robots.txt for %s: %w", host, err)
+		}
+		if resp.StatusCode >= 500 {
+			ttl = robotsErrorTTL
+		}
+	}
+
+	p.mu.Lock()
+	p.robots[key] = robotsEntry{data: data, expires: time.Now().Add(ttl)}
+	p.mu.Unlock()
+
+	return data, nil
+}
+
+// domain returns the request schedule of a host, creating it from the
+// policy on first use
+func (p *Politeness) domain(host string) *domainState {
+	host = strings.ToLower(host)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if d, ok := p.domains[host]; ok {
+		return d
+	}
+
+	policy := p.policyFor(host)
+	d := &domainState{
+		slots: make(chan struct{}, max(policy.Parallelism, 1)),
+		delay: policy.Delay,
+	}
+	p.domains[host] = d
+	return d
+}
+
+// policyFor returns the delay and concurrency for a host, using the most
+// specific domain override that matches it
+func (p *Politeness) policyFor(host string) DomainPolicy {
+	policy := DomainPolicy{Delay: p.Delay, Parallelism: p.Parallelism}
+
+	for domain := host; domain != ""; {
+		if override, ok := p.Domains[domain]; ok {
+			if override.Delay > 0 {
+				policy.Delay = override.Delay
+			}
+			if override.Parallelism > 0 {
+				policy.Parallelism = override.Parallelism
+			}
+			break
+		}
+		_, parent, ok := strings.Cut(domain, ".")
+		if !ok {
+			break
+		}
+		domain = parent
+	}
+
+	return policy
+}
+
+// wait blocks until the domain's next request may start and reserves the
+// following slot
+func (d *domainState) wait(ctx context.Context) error {
+	d.mu.Lock()
+	start := time.Now()
+	if d.next.After(start) {
+		start = d.next
+	}
+	d.next = start.Add(d.delay)
+	d.mu.Unlock()
+
+	delay := time.Until(start)
+	if delay <= 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// holdUntil delays the domain's next request until t
+func (d *domainState) holdUntil(t time.Time) {
+	d.mu.Lock()
+	if t.After(d.next) {
+		d.next = t
+	}
+	d.mu.Unlock()
+}
+
+// raiseDelay increases the domain's delay to at least delay, e.g. to honour
+// a robots.txt Crawl-delay
+func (d *domainState) raiseDelay(delay time.Duration) {
+	d.mu.Lock()
+	if delay > d.delay {
+		d.delay = delay
+	}
+	d.mu.Unlock()
+}
+
+// parseRetryAfter parses a Retry-After header given either in seconds or
+// as an HTTP date
+func parseRetryAfter(value string, now time.Time) (time.Duration, bool) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		return time.Duration(max(seconds, 0)) * time.Second, true
+	}
+	if t, err := http.ParseTime(value); err == nil {
+		return max(t.Sub(now), 0), true
+	}
+	return 0, false
+}
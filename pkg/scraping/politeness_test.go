@@ -0,0 +1,123 @@
+package scraping
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestPolitenessRobots(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/robots.txt" {
+			w.Write([]byte("User-agent: *\nDisallow: /private\n"))
+			return
+		}
+		w.Write([]byte(r.UserAgent()))
+	}))
+	defer srv.Close()
+
+	p := NewPoliteness()
+	p.Delay = 0
+	client := &http.Client{Transport: p}
+
+	if _, err := client.Get(srv.URL + "/private/page"); !errors.Is(err, ErrDisallowedByRobots) {
+		t.Errorf("disallowed page: got %v, want ErrDisallowedByRobots", err)
+	}
+
+	resp, err := client.Get(srv.URL + "/public")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("allowed page: status %d", resp.StatusCode)
+	}
+}
+
+// unreachableRobots fails every robots.txt request as if the host could not
+// be reached and answers every other request
+type unreachableRobots struct {
+	fetches int
+}
+
+func (u *unreachableRobots) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.URL.Path == "/robots.txt" {
+		u.fetches++
+		return nil, errors.New("connection refused")
+	}
+	return &http.Response{StatusCode: http.StatusOK, Body: http.NoBody, Request: req}, nil
+}
+
+func TestPolitenessUnreachableRobots(t *testing.T) {
+	base := &unreachableRobots{}
+	p := NewPoliteness()
+	p.Delay = 0
+	p.base = base
+	client := &http.Client{Transport: p}
+
+	// Pages are allowed and robots.txt is not fetched again for each one
+	for _, path := range []string{"/first", "/second"} {
+		resp, err := client.Get("http://shop.example.com" + path)
+		if err != nil {
+			t.Fatalf("%s: %v", path, err)
+		}
+		resp.Body.Close()
+	}
+	if base.fetches != 1 {
+		t.Errorf("robots.txt fetched %d times, want once", base.fetches)
+	}
+}
+
+func TestPolitenessRetryAfter(t *testing.T) {
+	hits := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/robots.txt" {
+			http.NotFound(w, r)
+			return
+		}
+		hits++
+		if hits == 1 {
+			w.Header().Set("Retry-After", "1")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		w.Write([]byte("ok"))
+	}))
+	defer srv.Close()
+
+	p := NewPoliteness()
+	p.Delay = 0
+	client := &http.Client{Transport: p}
+
+	start := time.Now()
+	resp, err := client.Get(srv.URL + "/busy")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || hits != 2 {
+		t.Errorf("status %d after %d requests, want 200 after 2", resp.StatusCode, hits)
+	}
+	if elapsed := time.Since(start); elapsed < time.Second {
+		t.Errorf("retried after %v, want at least the 1s Retry-After", elapsed)
+	}
+}
+
+func TestPolitenessHoldRespectsContext(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+
+	p := NewPoliteness()
+	p.Delay = 0
+	p.domain("127.0.0.1").holdUntil(time.Now().Add(time.Hour))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL+"/page", nil)
+	if _, err := (&http.Client{Transport: p}).Do(req); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("got %v, want context.DeadlineExceeded", err)
+	}
+}
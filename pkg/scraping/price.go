@@ -0,0 +1,202 @@
+package scraping
+
+import (
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Money is an amount in a currency given by its ISO 4217 code
+type Money struct {
+	Amount   float64
+	Currency string
+}
+
+// PriceFormat describes how a retailer writes prices. Separators left empty
+// are guessed from the text, and Currency is used when the text names no
+// currency.
+type PriceFormat struct {
+	Currency           string
+	DecimalSeparator   string
+	ThousandsSeparator string
+}
+
+// ParsePrice parses price text such as "$1,299.99", "1.299,00 €" or
+// "£79.99 - £99.99", guessing its separators. For a range the lowest price
+// is returned.
+func ParsePrice(text, currency string) (Money, bool) {
+	return PriceFormat{Currency: currency}.Parse(text)
+}
+
+// Parse parses price text, returning the lowest price of a range
+func (f PriceFormat) Parse(text string) (Money, bool) {
+	low, _, ok := f.ParseRange(text)
+	return low, ok
+}
+
+// ParseRange parses price text that may hold a range such as "$10 - $20"
+// or "10 to 20 EUR". Text with a single price returns it as both ends.
+func (f PriceFormat) ParseRange(text string) (Money, Money, bool) {
+	currency := f.currency(text)
+
+	matches := amountPattern.FindAllStringIndex(text, -1)
+	if len(matches) == 0 {
+		return Money{}, Money{}, false
+	}
+	low, ok := f.amount(text[matches[0][0]:matches[0][1]])
+	if !ok {
+		return Money{}, Money{}, false
+	}
+	high := low
+	if len(matches) > 1 && isRangeSeparator(text[matches[0][1]:matches[1][0]]) {
+		if amount, ok := f.amount(text[matches[1][0]:matches[1][1]]); ok {
+			low, high = math.Min(low, amount), math.Max(low, amount)
+		}
+	}
+
+	return Money{Amount: low, Currency: currency}, Money{Amount: high, Currency: currency}, true
+}
+
+// amountPattern matches a number with optional separators, including
+// digits grouped by spaces as in "1 299,00"
+var amountPattern = regexp.MustCompile(`\d{1,3}(?:[ \x{00a0}\x{202f}]\d{3})+(?:[.,]\d+)?|\d(?:[\d.,'’]*\d)?`)
+
+// amount parses a number written with the format's separators
+func (f PriceFormat) amount(s string) (float64, bool) {
+	// Spaces and apostrophes only ever group thousands
+	s = strings.Map(func(r rune) rune {
+		if unicode.IsSpace(r) || r == '\'' || r == '’' {
+			return -1
+		}
+		return r
+	}, s)
+
+	decimal := f.DecimalSeparator
+	switch {
+	case decimal == "" && f.ThousandsSeparator == "":
+		decimal = guessDecimalSeparator(s)
+	case decimal == "":
+		decimal = otherSeparator(f.ThousandsSeparator)
+	}
+
+	// Drop every separator but the decimal one, which becomes a point
+	s = strings.Map(func(r rune) rune {
+		switch {
+		case decimal != "" && string(r) == decimal:
+			return '.'
+		case r == '.' || r == ',' || string(r) == f.ThousandsSeparator:
+			return -1
+		}
+		return r
+	}, s)
+
+	amount, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0, false
+	}
+	return amount, true
+}
+
+// otherSeparator returns the separator a price does not use for sep
+func otherSeparator(sep string) string {
+	if sep == "," {
+		return "."
+	}
+	return ","
+}
+
+// guessDecimalSeparator guesses the decimal separator of a number. Of two
+// different separators the last is the decimal one; a single separator is
+// a decimal one unless it repeats or is followed by exactly three digits,
+// as in "1,299" or "1.299". An empty result means the number is whole.
+func guessDecimalSeparator(s string) string {
+	last := strings.LastIndexAny(s, ".,")
+	if last < 0 {
+		return ""
+	}
+	sep := s[last : last+1]
+	if strings.Contains(s, otherSeparator(sep)) {
+		return sep
+	}
+	if strings.Count(s, sep) > 1 {
+		return ""
+	}
+	if len(s)-last-1 == 3 && !strings.HasPrefix(s, "0") {
+		return ""
+	}
+	return sep
+}
+
+// isRangeSeparator reports whether the text between two amounts joins them
+// into a range, ignoring currency symbols and codes
+func isRangeSeparator(text string) bool {
+	text = strings.ToLower(text)
+	for _, c := range currencyCodes {
+		text = strings.ReplaceAll(text, strings.ToLower(c), "")
+	}
+	text = strings.TrimFunc(text, func(r rune) bool {
+		return unicode.IsSpace(r) || unicode.Is(unicode.Sc, r)
+	})
+	switch text {
+	case "-", "–", "—", "~", "to":
+		return true
+	}
+	return false
+}
+
+// currencyCodes are the ISO 4217 codes recognised in price text
+var currencyCodes = []string{
+	"USD", "CAD", "AUD", "NZD", "EUR", "GBP", "CHF", "JPY", "SEK", "NOK", "DKK", "PLN", "CZK",
+}
+
+// currencySymbols maps currency symbols to codes, longest symbols first so
+// that e.g. "CA$" is not taken for "A$"
+var currencySymbols = []struct {
+	symbol string
+	code   string
+}{
+	{"US$", "USD"},
+	{"CA$", "CAD"},
+	{"AU$", "AUD"},
+	{"NZ$", "NZD"},
+	{"C$", "CAD"},
+	{"A$", "AUD"},
+	{"€", "EUR"},
+	{"£", "GBP"},
+	{"¥", "JPY"},
+	{"zł", "PLN"},
+	{"Kč", "CZK"},
+}
+
+// dollarCurrencies are the currencies a bare "$" may stand for
+var dollarCurrencies = map[string]bool{"USD": true, "CAD": true, "AUD": true, "NZD": true}
+
+// currencyCodePattern matches a word that may be a currency code
+var currencyCodePattern = regexp.MustCompile(`\b[A-Za-z]{3}\b`)
+
+// currency returns the currency named in price text by ISO code or symbol,
+// or the format's currency. A bare "$" is the format's currency when that
+// is a dollar currency and USD otherwise.
+func (f PriceFormat) currency(text string) string {
+	fallback := strings.ToUpper(f.Currency)
+
+	for _, word := range currencyCodePattern.FindAllString(text, -1) {
+		word = strings.ToUpper(word)
+		for _, code := range currencyCodes {
+			if word == code {
+				return code
+			}
+		}
+	}
+	for _, s := range currencySymbols {
+		if strings.Contains(text, s.symbol) {
+			return s.code
+		}
+	}
+	if strings.Contains(text, "$") && !dollarCurrencies[fallback] {
+		return "USD"
+	}
+	return fallback
+}
@@ -0,0 +1,60 @@
+package scraping
+
+import "testing"
+
+func TestParsePrice(t *testing.T) {
+	tests := []struct {
+		text     string
+		currency string
+		amount   float64
+		code     string
+	}{
+		{"$1,299.99", "", 1299.99, "USD"},
+		{"1.299,00 €", "", 1299, "EUR"},
+		{"£79.99 - £99.99", "", 79.99, "GBP"},
+		{"1 299,00 zł", "", 1299, "PLN"},
+		{"CHF 1'299.50", "", 1299.5, "CHF"},
+		{"$49", "CAD", 49, "CAD"},
+		{"$49", "EUR", 49, "USD"},
+		{"CA$59.00", "", 59, "CAD"},
+		{"10 to 20 EUR", "", 10, "EUR"},
+		{"0,99", "EUR", 0.99, "EUR"},
+		{"1,299", "USD", 1299, "USD"},
+	}
+	for _, tt := range tests {
+		money, ok := ParsePrice(tt.text, tt.currency)
+		if !ok {
+			t.Errorf("ParsePrice(%q) failed", tt.text)
+			continue
+		}
+		if money.Amount != tt.amount || money.Currency != tt.code {
+			t.Errorf("ParsePrice(%q, %q) = %v %s, want %v %s", tt.text, tt.currency, money.Amount, money.Currency, tt.amount, tt.code)
+		}
+	}
+
+	if _, ok := ParsePrice("Call for price", "USD"); ok {
+		t.Error("ParsePrice parsed text without a number")
+	}
+}
+
+func TestParsePriceRange(t *testing.T) {
+	low, high, ok := PriceFormat{Currency: "USD"}.ParseRange("$99.99 – $49.99")
+	if !ok || low.Amount != 49.99 || high.Amount != 99.99 {
+		t.Errorf("got %v-%v, want 49.99-99.99", low.Amount, high.Amount)
+	}
+
+	// Two prices that are not a range, such as a sale and list price
+	low, high, ok = PriceFormat{Currency: "USD"}.ParseRange("$79.99 was $99.99")
+	if !ok || low.Amount != 79.99 || high.Amount != 79.99 {
+		t.Errorf("got %v-%v, want 79.99 only", low.Amount, high.Amount)
+	}
+}
+
+func BenchmarkParsePrice(b *testing.B) {
+	texts := []string{"$1,299.99", "1.299,00 €", "£79.99 - £99.99", "CHF 1'299.50", "Call for price"}
+	for b.Loop() {
+		for _, text := range texts {
+			ParsePrice(text, "USD")
+		}
+	}
+}
@@ -0,0 +1,574 @@
+package scraping
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	neturl "net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gocolly/colly/v2"
+	"github.com/sosadtsia/bike-parts-finder/pkg/models"
+)
+
+func init() {
+	registerFallback(func() Scraper { return NewSchemaOrgScraper() })
+}
+
+// SchemaOrgScraper is a generic scraper for any retailer that embeds
+// schema.org Product data as JSON-LD or microdata. It is used for domains
+// without a dedicated scraper.
+type SchemaOrgScraper struct{}
+
+// NewSchemaOrgScraper creates a new schema.org scraper
+func NewSchemaOrgScraper() *SchemaOrgScraper {
+	return &SchemaOrgScraper{}
+}
+
+// Name returns the name of the generic schema.org scraper
+func (s *SchemaOrgScraper) Name() string {
+	return "SchemaOrg"
+}
+
+// CanHandle checks if the URL is an absolute http(s) URL
+func (s *SchemaOrgScraper) CanHandle(url string) bool {
+	u, err := neturl.Parse(url)
+	if err != nil {
+		return false
+	}
+	return (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
+
+// Scrape scrapes schema.org products from the page at the request URL,
+// following ItemList entries and rel="next" links on listing pages
+func (s *SchemaOrgScraper) Scrape(ctx context.Context, req models.ScrapeRequest) (models.ScrapeResult, error) {
+	var parts []models.Part
+
+	u, err := neturl.Parse(req.URL)
+	if err != nil {
+		return models.ScrapeResult{}, fmt.Errorf("parsing URL %s: %w", req.URL, err)
+	}
+	source := strings.TrimPrefix(u.Hostname(), "www.")
+
+	cr, cancel := newCrawl(ctx, req)
+	defer cancel()
+
+	// Initialize the collector
+	c := cr.newCollector(
+		colly.AllowedDomains(u.Hostname()),
+	)
+
+	c.OnHTML("html", func(e *colly.HTMLElement) {
+		page := parseSchemaOrgPage(e)
+
+		for _, product := range page.products {
+			part := product.toPart()
+			if part.Model == "" {
+				continue
+			}
+
+			if part.URL == "" {
+				part.URL = e.Request.URL.String()
+			} else {
+				part.URL = e.Request.AbsoluteURL(part.URL)
+			}
+			for i := range part.Variants {
+				if part.Variants[i].URL != "" {
+					part.Variants[i].URL = e.Request.AbsoluteURL(part.Variants[i].URL)
+				}
+			}
+			part.Source = source
+			if len(part.Breadcrumbs) == 0 {
+				setBreadcrumbs(&part, page.breadcrumbs)
+			}
+			identifyPart(&part)
+
+			now := time.Now()
+			part.CreatedAt = now
+			part.UpdatedAt = now
+
+			parts = append(parts, part)
+		}
+
+		// Follow product links and pagination on listing pages
+		for _, link := range page.links {
+			if link = e.Request.AbsoluteURL(link); link != "" {
+				cr.visit(e.Request, link)
+			}
+		}
+	})
+
+	// Start the scraping
+	if err := cr.start(c, req.URL); err != nil {
+		return models.ScrapeResult{}, err
+	}
+
+	// Wait for scraping to finish
+	c.Wait()
+
+	return cr.result(req, parts), nil
+}
+
+// schemaOrgPage holds the schema.org data found on a single page
+type schemaOrgPage struct {
+	products    []schemaOrgProduct
+	breadcrumbs []string
+	links       []string
+}
+
+// schemaOrgProduct is the subset of a schema.org Product used to build a part
+type schemaOrgProduct struct {
+	Name         string
+	Brand        string
+	Description  string
+	URL          string
+	Category     string
+	Images       []string
+	SKU          string
+	MPN          string
+	GTIN         string
+	Price        string
+	ListPrice    string
+	Currency     string
+	Availability string
+	Rating       string
+	NumReviews   string
+	Properties   []models.Spec
+	Attributes   map[string]string
+	Variants     []schemaOrgProduct
+}
+
+// parseSchemaOrgPage extracts products from JSON-LD, falling back to
+// microdata when the page has no JSON-LD products
+func parseSchemaOrgPage(e *colly.HTMLElement) schemaOrgPage {
+	var page schemaOrgPage
+
+	e.ForEach(`script[type="application/ld+json"]`, func(_ int, el *colly.HTMLElement) {
+		var doc any
+		if err := json.Unmarshal([]byte(el.Text), &doc); err != nil {
+			return
+		}
+		for _, node := range ldNodes(doc) {
+			switch {
+			case ldIsType(node, "Product", "ProductGroup"):
+				page.products = append(page.products, parseLDProduct(node))
+			case ldIsType(node, "BreadcrumbList"):
+				page.breadcrumbs = parseLDBreadcrumbs(node)
+			case ldIsType(node, "ItemList"):
+				page.links = append(page.links, parseLDItemList(node)...)
+			}
+		}
+	})
+
+	if len(page.products) == 0 {
+		e.ForEach(`[itemscope][itemtype$="schema.org/Product"]`, func(_ int, el *colly.HTMLElement) {
+			page.products = append(page.products, parseMicrodataProduct(el))
+		})
+	}
+
+	if next := e.ChildAttr(`link[rel="next"]`, "href"); next != "" {
+		page.links = append(page.links, next)
+	}
+
+	return page
+}
+
+// toPart converts the schema.org product into a part
+func (p schemaOrgProduct) toPart() models.Part {
+	part := models.Part{
+		GTIN:        p.GTIN,
+		MPN:         p.MPN,
+		SKU:         p.SKU,
+		Description: p.Description,
+		URL:         p.URL,
+		Images:      p.Images,
+		Specs:       p.Properties,
+	}
+
+	// Without a brand property the brand is taken from the name
+	if strings.TrimSpace(p.Name) != "" {
+		part.Brand, part.Model = splitBrand(p.Brand, p.Name)
+	}
+
+	// Categories are commonly given as a "Components > Brakes" path
+	if p.Category != "" {
+		setBreadcrumbs(&part, strings.FieldsFunc(p.Category, func(r rune) bool { return r == '>' || r == '/' }))
+	}
+
+	// Schema.org prices use a decimal point, though microdata text may
+	// still carry a currency symbol
+	format := PriceFormat{Currency: p.Currency, DecimalSeparator: "."}
+	if price, ok := format.Parse(p.Price); ok {
+		part.Price = price.Amount
+		part.Currency = price.Currency
+	}
+	// Only an explicit list price is the MSRP; the highPrice of an
+	// AggregateOffer is merely the dearest of several offers
+	if msrp, ok := format.Parse(p.ListPrice); ok {
+		part.MSRP = msrp.Amount
+		if part.Price > 0 && part.MSRP > part.Price {
+			part.Discount = ((part.MSRP - part.Price) / part.MSRP) * 100
+		}
+	}
+
+	part.InStock = ldInStock(p.Availability)
+
+	for _, v := range p.Variants {
+		variant := models.Variant{
+			Attributes: v.Attributes,
+			SKU:        v.SKU,
+			GTIN:       v.GTIN,
+			InStock:    ldInStock(v.Availability),
+			URL:        v.URL,
+		}
+		// Variants are commonly named after the product and their options
+		if len(v.Attributes) == 0 {
+			variant.Title = v.Name
+		}
+		if price, ok := (PriceFormat{Currency: v.Currency, DecimalSeparator: "."}).Parse(v.Price); ok {
+			variant.Price = price.Amount
+			if part.Currency == "" {
+				part.Currency = price.Currency
+			}
+		}
+		if msrp, ok := (PriceFormat{Currency: v.Currency, DecimalSeparator: "."}).Parse(v.ListPrice); ok {
+			variant.MSRP = msrp.Amount
+		}
+		if len(v.Images) > 0 {
+			variant.Image = v.Images[0]
+		}
+		part.Variants = append(part.Variants, variant)
+	}
+	summarizeVariants(&part)
+
+	if rating, err := strconv.ParseFloat(p.Rating, 64); err == nil {
+		part.Rating = rating
+	}
+	if reviews, err := strconv.Atoi(p.NumReviews); err == nil {
+		part.NumReviews = reviews
+	}
+
+	return part
+}
+
+// parseLDProduct reads a JSON-LD Product node
+func parseLDProduct(node map[string]any) schemaOrgProduct {
+	p := schemaOrgProduct{
+		Name:        ldString(node["name"]),
+		Brand:       ldString(node["brand"]),
+		Description: ldString(node["description"]),
+		URL:         ldString(node["url"]),
+		Category:    ldString(node["category"]),
+		Images:      ldStrings(node["image"]),
+		SKU:         ldString(node["sku"]),
+		MPN:         ldString(node["mpn"]),
+		GTIN:        ldGTIN(node),
+	}
+	if p.Brand == "" {
+		p.Brand = ldString(node["manufacturer"])
+	}
+
+	// Offers may be a single Offer, an AggregateOffer or a list of offers
+	for _, offer := range ldObjects(node["offers"]) {
+		price := ldString(offer["price"])
+		if price == "" {
+			price = ldString(offer["lowPrice"])
+		}
+		if price == "" {
+			continue
+		}
+		if p.Price == "" {
+			p.Price = price
+			p.ListPrice = ldListPrice(offer)
+			p.Currency = ldString(offer["priceCurrency"])
+		}
+		// A product is in stock when any of its offers is
+		if availability := ldString(offer["availability"]); p.Availability == "" ||
+			strings.HasSuffix(strings.ToLower(availability), "instock") {
+			p.Availability = availability
+		}
+		if p.SKU == "" {
+			p.SKU = ldString(offer["sku"])
+		}
+		if p.GTIN == "" {
+			p.GTIN = ldGTIN(offer)
+		}
+	}
+
+	for _, rating := range ldObjects(node["aggregateRating"]) {
+		p.Rating = ldString(rating["ratingValue"])
+		p.NumReviews = ldString(rating["reviewCount"])
+		if p.NumReviews == "" {
+			p.NumReviews = ldString(rating["ratingCount"])
+		}
+	}
+
+	for _, prop := range ldObjects(node["additionalProperty"]) {
+		name, value := ldString(prop["name"]), ldString(prop["value"])
+		if name != "" && value != "" {
+			p.Properties = append(p.Properties, models.Spec{Name: name, Value: value})
+		}
+	}
+
+	// A ProductGroup lists its variants as products with their own offers,
+	// distinguished by the properties named in variesBy
+	variesBy := ldStrings(node["variesBy"])
+	if len(variesBy) == 0 {
+		variesBy = []string{"size", "color", "material", "pattern"}
+	}
+	for _, v := range ldObjects(node["hasVariant"]) {
+		variant := parseLDProduct(v)
+		for _, prop := range variesBy {
+			prop = prop[strings.LastIndex(prop, "/")+1:]
+			if value := ldString(v[prop]); value != "" && prop != "" {
+				if variant.Attributes == nil {
+					variant.Attributes = make(map[string]string)
+				}
+				variant.Attributes[strings.ToUpper(prop[:1])+prop[1:]] = value
+			}
+		}
+		p.Variants = append(p.Variants, variant)
+	}
+
+	return p
+}
+
+// ldInStock reports whether a schema.org availability such as
+// https://schema.org/InStock means the product can be bought
+func ldInStock(availability string) bool {
+	availability = strings.ToLower(availability)
+	return strings.HasSuffix(availability, "instock") ||
+		strings.HasSuffix(availability, "limitedavailability") ||
+		strings.HasSuffix(availability, "onlineonly")
+}
+
+// parseLDBreadcrumbs reads the names of a JSON-LD BreadcrumbList, skipping
+// the home page
+func parseLDBreadcrumbs(node map[string]any) []string {
+	var crumbs []string
+	for _, item := range ldObjects(node["itemListElement"]) {
+		name := ldString(item["name"])
+		if name == "" {
+			name = ldString(item["item"])
+		}
+		if name != "" && !strings.EqualFold(name, "Home") {
+			crumbs = append(crumbs, name)
+		}
+	}
+	return crumbs
+}
+
+// parseLDItemList reads the URLs listed by a JSON-LD ItemList
+func parseLDItemList(node map[string]any) []string {
+	var links []string
+	for _, item := range ldObjects(node["itemListElement"]) {
+		link := ldString(item["url"])
+		if link == "" {
+			if inner := ldObjects(item["item"]); len(inner) > 0 {
+				link = ldString(inner[0]["url"])
+			} else {
+				link = ldString(item["item"])
+			}
+		}
+		if link != "" {
+			links = append(links, link)
+		}
+	}
+	return links
+}
+
+// parseMicrodataProduct reads a schema.org Product marked up with microdata
+func parseMicrodataProduct(el *colly.HTMLElement) schemaOrgProduct {
+	p := schemaOrgProduct{
+		Name:        microdataValue(el, "name"),
+		Brand:       microdataValue(el, "brand"),
+		Description: microdataValue(el, "description"),
+		URL:         microdataValue(el, "url"),
+		Category:    microdataValue(el, "category"),
+		SKU:         microdataValue(el, "sku"),
+		MPN:         microdataValue(el, "mpn"),
+		Price:       microdataValue(el, "price"),
+		Currency:    microdataValue(el, "priceCurrency"),
+		Rating:      microdataValue(el, "ratingValue"),
+		NumReviews:  microdataValue(el, "reviewCount"),
+	}
+	if p.Price == "" {
+		p.Price = microdataValue(el, "lowPrice")
+	}
+	for _, prop := range []string{"gtin", "gtin13", "gtin12", "gtin14", "gtin8"} {
+		if p.GTIN = microdataValue(el, prop); p.GTIN != "" {
+			break
+		}
+	}
+
+	el.ForEach(`[itemprop="availability"]`, func(_ int, a *colly.HTMLElement) {
+		if p.Availability == "" {
+			p.Availability = microdataAttr(a)
+		}
+	})
+	el.ForEach(`[itemprop="image"]`, func(_ int, img *colly.HTMLElement) {
+		if src := microdataAttr(img); src != "" {
+			p.Images = append(p.Images, img.Request.AbsoluteURL(src))
+		}
+	})
+
+	return p
+}
+
+// microdataValue returns the value of an itemprop with the given name,
+// preferring one that belongs to the item itself over one of a nested item
+// (e.g. the product's name rather than its brand's name)
+func microdataValue(el *colly.HTMLElement, prop string) string {
+	var own, nested string
+	el.ForEach(fmt.Sprintf(`[itemprop="%s"]`, prop), func(_ int, child *colly.HTMLElement) {
+		value := microdataAttr(child)
+		if value == "" {
+			return
+		}
+		if child.DOM.ParentsFiltered("[itemscope]").First().IsSelection(el.DOM) {
+			if own == "" {
+				own = value
+			}
+		} else if nested == "" {
+			nested = value
+		}
+	})
+	if own != "" {
+		return own
+	}
+	return nested
+}
+
+// microdataAttr returns the value of an itemprop element, which is held in
+// an attribute for meta, link, img and data elements and in the text otherwise
+func microdataAttr(el *colly.HTMLElement) string {
+	for _, attr := range []string{"content", "href", "src", "value"} {
+		if v := el.Attr(attr); v != "" {
+			return strings.TrimSpace(v)
+		}
+	}
+	// A brand or offer may be a nested item whose name is its value
+	if el.DOM.Is("[itemscope]") {
+		if name := microdataValue(el, "name"); name != "" {
+			return name
+		}
+	}
+	return strings.TrimSpace(el.Text)
+}
+
+// ldNodes flattens a JSON-LD document into its top-level nodes, expanding
+// arrays and @graph containers
+func ldNodes(doc any) []map[string]any {
+	var nodes []map[string]any
+	switch v := doc.(type) {
+	case []any:
+		for _, item := range v {
+			nodes = append(nodes, ldNodes(item)...)
+		}
+	case map[string]any:
+		if graph, ok := v["@graph"]; ok {
+			nodes = append(nodes, ldNodes(graph)...)
+		} else {
+			nodes = append(nodes, v)
+		}
+	}
+	return nodes
+}
+
+// ldIsType reports whether a JSON-LD node has one of the given types
+func ldIsType(node map[string]any, types ...string) bool {
+	for _, t := range ldStrings(node["@type"]) {
+		t = strings.TrimPrefix(strings.TrimPrefix(t, "http://schema.org/"), "https://schema.org/")
+		for _, want := range types {
+			if t == want {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// ldGTIN returns the first GTIN property set on a JSON-LD node
+func ldGTIN(node map[string]any) string {
+	for _, key := range []string{"gtin", "gtin13", "gtin12", "gtin14", "gtin8"} {
+		if v := ldString(node[key]); v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// ldObjects returns a JSON-LD value as a list of objects
+func ldObjects(v any) []map[string]any {
+	switch v := v.(type) {
+	case map[string]any:
+		return []map[string]any{v}
+	case []any:
+		var objects []map[string]any
+		for _, item := range v {
+			if obj, ok := item.(map[string]any); ok {
+				objects = append(objects, obj)
+			}
+		}
+		return objects
+	}
+	return nil
+}
+
+// ldListPrice returns the price of an offer's priceSpecification with a
+// ListPrice, MSRP or SRP price type
+func ldListPrice(offer map[string]any) string {
+	for _, spec := range ldObjects(offer["priceSpecification"]) {
+		// Price types are schema.org URLs or bare names
+		priceType := ldString(spec["priceType"])
+		switch priceType[strings.LastIndex(priceType, "/")+1:] {
+		case "ListPrice", "MSRP", "SRP":
+			return ldString(spec["price"])
+		}
+	}
+	return ""
+}
+
+// ldString returns a JSON-LD value as a string. Objects are reduced to
+// their name, @value, url or @id.
+func ldString(v any) string {
+	switch v := v.(type) {
+	case string:
+		return strings.TrimSpace(v)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(v)
+	case map[string]any:
+		for _, key := range []string{"name", "@value", "url", "@id"} {
+			if s := ldString(v[key]); s != "" {
+				return s
+			}
+		}
+	case []any:
+		if len(v) > 0 {
+			return ldString(v[0])
+		}
+	}
+	return ""
+}
+
+// ldStrings returns a JSON-LD value that may be a single value or a list
+// as a list of strings
+func ldStrings(v any) []string {
+	var values []string
+	if list, ok := v.([]any); ok {
+		for _, item := range list {
+			if s := ldString(item); s != "" {
+				values = append(values, s)
+			}
+		}
+		return values
+	}
+	if s := ldString(v); s != "" {
+		values = append(values, s)
+	}
+	return values
+}
@@ -0,0 +1,66 @@
+package scraping_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/sosadtsia/bike-parts-finder/pkg/models"
+	"github.com/sosadtsia/bike-parts-finder/pkg/scraping"
+	"github.com/sosadtsia/bike-parts-finder/pkg/scraping/scrapetest"
+)
+
+func TestSchemaOrgFixtures(t *testing.T) {
+	scrapetest.Run(t, scrapetest.Case{
+		New:      func(string) scraping.Scraper { return scraping.NewSchemaOrgScraper() },
+		Fixtures: "testdata/schemaorg",
+		Path:     "/collections/forks",
+		Golden:   "testdata/schemaorg/forks.golden.json",
+	})
+}
+
+// scrapeSchemaOrgProduct scrapes a page holding a JSON-LD product
+func scrapeSchemaOrgProduct(t *testing.T, product string) models.Part {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`<html><head><script type="application/ld+json">` + product + `</script></head></html>`))
+	}))
+	defer srv.Close()
+
+	result, err := scraping.NewSchemaOrgScraper().Scrape(context.Background(), models.ScrapeRequest{URL: srv.URL + "/p/fork"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Parts) != 1 {
+		t.Fatalf("got %d parts, want 1", len(result.Parts))
+	}
+	return result.Parts[0]
+}
+
+func TestSchemaOrgListPrice(t *testing.T) {
+	disablePoliteness(t)
+
+	part := scrapeSchemaOrgProduct(t, `{
+		"@type": "Product", "name": "RockShox Lyrik Ultimate",
+		"offers": {
+			"@type": "Offer", "price": "899.00", "priceCurrency": "USD",
+			"priceSpecification": [
+				{"@type": "UnitPriceSpecification", "priceType": "https://schema.org/SalePrice", "price": "899.00"},
+				{"@type": "UnitPriceSpecification", "priceType": "https://schema.org/ListPrice", "price": "1199.00"}
+			]
+		}
+	}`)
+	if part.Price != 899 || part.MSRP != 1199 || part.Discount < 25 || part.Discount > 25.03 {
+		t.Errorf("got price %v, MSRP %v, discount %v; want 899, 1199 and 25%%", part.Price, part.MSRP, part.Discount)
+	}
+
+	// The high price of several offers is not a list price
+	part = scrapeSchemaOrgProduct(t, `{
+		"@type": "Product", "name": "DT Swiss F 535 One",
+		"offers": {"@type": "AggregateOffer", "lowPrice": "899.00", "highPrice": "1199.00", "priceCurrency": "USD", "offerCount": 4}
+	}`)
+	if part.Price != 899 || part.MSRP != 0 || part.Discount != 0 {
+		t.Errorf("got price %v, MSRP %v, discount %v; want 899 without an MSRP", part.Price, part.MSRP, part.Discount)
+	}
+}
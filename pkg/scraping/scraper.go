@@ -0,0 +1,147 @@
+package scraping
+
+import (
+	"context"
+	"strings"
+
+	"github.com/sosadtsia/bike-parts-finder/pkg/models"
+)
+
+// Scraper extracts bike parts from a retailer website
+type Scraper interface {
+	// Name returns the source name recorded on scraped parts
+	Name() string
+	// CanHandle checks if this scraper can handle the given URL
+	CanHandle(url string) bool
+	// Scrape scrapes bike parts for the request, stopping when ctx is
+	// cancelled or the request's limits are reached
+	Scrape(ctx context.Context, req models.ScrapeRequest) (models.ScrapeResult, error)
+}
+
+// factories holds the constructors of every built-in scraper. Retailer
+// files add themselves with register from an init function.
+var factories []func() Scraper
+
+// fallbackFactory constructs the scraper used for URLs no retailer
+// scraper can handle
+var fallbackFactory func() Scraper
+
+// register adds a scraper constructor to the default registry
+func register(factory func() Scraper) {
+	factories = append(factories, factory)
+}
+
+// registerFallback sets the fallback scraper constructor of the default registry
+func registerFallback(factory func() Scraper) {
+	fallbackFactory = factory
+}
+
+// Registry holds the scrapers available to the scraper command
+type Registry struct {
+	scrapers []Scraper
+	fallback Scraper
+}
+
+// NewRegistry creates a new registry with the given scrapers
+func NewRegistry(scrapers ...Scraper) *Registry {
+	r := &Registry{}
+	for _, s := range scrapers {
+		r.Register(s)
+	}
+	return r
+}
+
+// NewDefaultRegistry creates a registry with the built-in scrapers. If
+// enabled is non-empty only scrapers whose name matches one of its entries
+// (case-insensitively) are registered.
+func NewDefaultRegistry(enabled ...string) *Registry {
+	return newRegistry(nil, enabled)
+}
+
+// NewConfiguredRegistry creates a registry with the selector scrapers
+// defined in configDir followed by the built-in scrapers, so a retailer
+// definition takes precedence over a built-in scraper for the same domain.
+// enabled filters scrapers as for NewDefaultRegistry.
+func NewConfiguredRegistry(configDir string, enabled ...string) (*Registry, error) {
+	var configured []Scraper
+	if configDir != "" {
+		scrapers, err := LoadSelectorScrapers(configDir)
+		if err != nil {
+			return nil, err
+		}
+		for _, s := range scrapers {
+			configured = append(configured, s)
+		}
+	}
+	return newRegistry(configured, enabled), nil
+}
+
+// newRegistry creates a registry with the given scrapers and the built-in
+// scrapers, keeping only those named in enabled if it is non-empty
+func newRegistry(scrapers []Scraper, enabled []string) *Registry {
+	allowed := make(map[string]bool)
+	for _, name := range enabled {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name != "" {
+			allowed[name] = true
+		}
+	}
+	isEnabled := func(s Scraper) bool {
+		return len(allowed) == 0 || allowed[strings.ToLower(s.Name())]
+	}
+
+	for _, factory := range factories {
+		scrapers = append(scrapers, factory())
+	}
+
+	r := &Registry{}
+	for _, s := range scrapers {
+		if isEnabled(s) {
+			r.Register(s)
+		}
+	}
+
+	if fallbackFactory != nil {
+		if s := fallbackFactory(); isEnabled(s) {
+			r.SetFallback(s)
+		}
+	}
+	return r
+}
+
+// Register adds a scraper to the registry
+func (r *Registry) Register(s Scraper) {
+	r.scrapers = append(r.scrapers, s)
+}
+
+// SetFallback sets the scraper used when no registered scraper can handle a URL
+func (r *Registry) SetFallback(s Scraper) {
+	r.fallback = s
+}
+
+// ScraperFor returns the first registered scraper that can handle the URL,
+// or the fallback scraper if none can
+func (r *Registry) ScraperFor(url string) (Scraper, bool) {
+	for _, s := range r.scrapers {
+		if s.CanHandle(url) {
+			return s, true
+		}
+	}
+	if r.fallback != nil && r.fallback.CanHandle(url) {
+		return r.fallback, true
+	}
+	return nil, false
+}
+
+// Sources returns the names of the registered scrapers, including the
+// fallback scraper
+func (r *Registry) Sources() []string {
+	names := make([]string, 0, len(r.scrapers)+1)
+	for _, s := range r.scrapers {
+		names = append(names, s.Name())
+	}
+	if r.fallback != nil {
+		names = append(names, r.fallback.Name())
+	}
+	return names
+}
@@ -0,0 +1,168 @@
+// Package scrapetest runs retailer scrapers offline against recorded HTML
+// fixtures and compares their output with golden files.
+//
+// Fixtures live in a directory that mirrors the retailer's URL paths: a
+// request for /categories/brakes?page=2 is answered with the file
+// categories/brakes__page=2.html and /products.json?page=1 with
+// products__page=1.json (the query is omitted when empty). Set
+// UPDATE_GOLDEN=1 to rewrite golden files from the current scraper output.
+package scrapetest
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sosadtsia/bike-parts-finder/pkg/models"
+	"github.com/sosadtsia/bike-parts-finder/pkg/scraping"
+)
+
+// FixtureHost replaces the test server address in normalized output so
+// golden files do not depend on the port the server was started on
+const FixtureHost = "http://fixtures.test"
+
+// Case describes a single fixture-based scraper run
+type Case struct {
+	// New creates the scraper under test pointed at the fixture server
+	New func(baseURL string) scraping.Scraper
+	// Fixtures is the directory served by the fixture server
+	Fixtures string
+	// Path is the URL path (and query) the scrape starts from
+	Path string
+	// Golden is the file holding the expected parts as JSON
+	Golden string
+}
+
+// NewServer starts a server answering requests with the fixtures in dir.
+// Requests without a matching fixture receive a 404. The server is closed
+// when the test finishes.
+func NewServer(t testing.TB, dir string) *httptest.Server {
+	t.Helper()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.ServeFile(w, r, filepath.Join(dir, FixturePath(r.URL.Path, r.URL.RawQuery)))
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// FixturePath returns the fixture file name for a request path and query
+func FixturePath(urlPath, rawQuery string) string {
+	name := strings.Trim(path.Clean("/"+urlPath), "/")
+	if name == "" {
+		name = "index"
+	}
+	ext := path.Ext(name)
+	name = strings.TrimSuffix(name, ext)
+	if ext == "" {
+		ext = ".html"
+	}
+	if rawQuery != "" {
+		name += "__" + strings.ReplaceAll(rawQuery, "/", "_")
+	}
+	return filepath.FromSlash(name + ext)
+}
+
+// Run scrapes the case's start page from a fixture server and asserts the
+// normalized parts match the golden file. Crawl delays are disabled for the
+// duration of the run.
+func Run(t *testing.T, tc Case) {
+	t.Helper()
+
+	politeness := scraping.NewPoliteness()
+	politeness.Delay = 0
+	scraping.SetPoliteness(politeness)
+	t.Cleanup(func() { scraping.SetPoliteness(scraping.NewPoliteness()) })
+
+	srv := NewServer(t, tc.Fixtures)
+	scraper := tc.New(srv.URL)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	result, err := scraper.Scrape(ctx, models.ScrapeRequest{
+		ID:  "fixture",
+		URL: srv.URL + tc.Path,
+	})
+	if err != nil {
+		t.Fatalf("scraping %s: %v", tc.Path, err)
+	}
+
+	AssertGolden(t, Normalize(result.Parts, srv.URL), tc.Golden)
+}
+
+// Normalize returns a copy of parts with values that change between runs
+// removed: timestamps and IDs are zeroed, the server address is replaced
+// with FixtureHost and parts are sorted by URL
+func Normalize(parts []models.Part, baseURL string) []models.Part {
+	out := make([]models.Part, len(parts))
+	for i, part := range parts {
+		part.ID = ""
+		part.CreatedAt = time.Time{}
+		part.UpdatedAt = time.Time{}
+		part.URL = strings.ReplaceAll(part.URL, baseURL, FixtureHost)
+
+		images := make([]string, len(part.Images))
+		for j, img := range part.Images {
+			images[j] = strings.ReplaceAll(img, baseURL, FixtureHost)
+		}
+		if len(images) > 0 {
+			part.Images = images
+		}
+
+		if len(part.Variants) > 0 {
+			variants := make([]models.Variant, len(part.Variants))
+			for j, variant := range part.Variants {
+				variant.URL = strings.ReplaceAll(variant.URL, baseURL, FixtureHost)
+				variant.Image = strings.ReplaceAll(variant.Image, baseURL, FixtureHost)
+				variants[j] = variant
+			}
+			part.Variants = variants
+		}
+
+		out[i] = part
+	}
+
+	sort.SliceStable(out, func(i, j int) bool {
+		return out[i].URL < out[j].URL
+	})
+	return out
+}
+
+// AssertGolden compares parts with the JSON golden file, rewriting the
+// file instead when UPDATE_GOLDEN is set
+func AssertGolden(t testing.TB, parts []models.Part, golden string) {
+	t.Helper()
+
+	got, err := json.MarshalIndent(parts, "", "  ")
+	if err != nil {
+		t.Fatalf("marshaling parts: %v", err)
+	}
+	got = append(got, '\n')
+
+	if os.Getenv("UPDATE_GOLDEN") != "" {
+		if err := os.MkdirAll(filepath.Dir(golden), 0o755); err != nil {
+			t.Fatalf("creating golden directory: %v", err)
+		}
+		if err := os.WriteFile(golden, got, 0o644); err != nil {
+			t.Fatalf("writing golden file %s: %v", golden, err)
+		}
+		return
+	}
+
+	want, err := os.ReadFile(golden)
+	if err != nil {
+		t.Fatalf("reading golden file %s: %v", golden, err)
+	}
+	if string(got) != string(want) {
+		t.Errorf("parts do not match golden file %s\n--- got ---\n%s\n--- want ---\n%s", golden, got, want)
+	}
+}
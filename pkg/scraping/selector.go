@@ -0,0 +1,413 @@
+package scraping
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	neturl "net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/gocolly/colly/v2"
+	"github.com/sosadtsia/bike-parts-finder/pkg/models"
+	"gopkg.in/yaml.v3"
+)
+
+// SelectorConfig defines a retailer scraped with CSS selectors. Field
+// selectors are relative to the product container and read the element's
+// text, or an attribute when written as "selector@attr".
+type SelectorConfig struct {
+	Name           string             `yaml:"name" json:"name"`
+	BaseURL        string             `yaml:"base_url" json:"base_url"`
+	AllowedDomains []string           `yaml:"allowed_domains" json:"allowed_domains"`
+	Currency       string             `yaml:"currency" json:"currency"`
+	Listing        ListingConfig      `yaml:"listing" json:"listing"`
+	Product        ProductConfig      `yaml:"product" json:"product"`
+	PriceCleanup   PriceCleanupConfig `yaml:"price_cleanup" json:"price_cleanup"`
+}
+
+// ListingConfig defines how product links are found on category pages
+type ListingConfig struct {
+	Tile       string `yaml:"tile" json:"tile"`
+	Link       string `yaml:"link" json:"link"`
+	Pagination string `yaml:"pagination" json:"pagination"`
+}
+
+// ProductConfig defines the selectors of a product page
+type ProductConfig struct {
+	Container      string         `yaml:"container" json:"container"`
+	Name           string         `yaml:"name" json:"name"`
+	Brand          string         `yaml:"brand" json:"brand"`
+	Price          []string       `yaml:"price" json:"price"`
+	MSRP           string         `yaml:"msrp" json:"msrp"`
+	Stock          string         `yaml:"stock" json:"stock"`
+	OutOfStockText []string       `yaml:"out_of_stock_text" json:"out_of_stock_text"`
+	Description    string         `yaml:"description" json:"description"`
+	Breadcrumbs    string         `yaml:"breadcrumbs" json:"breadcrumbs"`
+	Images         string         `yaml:"images" json:"images"`
+	SKU            string         `yaml:"sku" json:"sku"`
+	MPN            string         `yaml:"mpn" json:"mpn"`
+	GTIN           string         `yaml:"gtin" json:"gtin"`
+	Specs          SpecsConfig    `yaml:"specs" json:"specs"`
+	Variants       VariantsConfig `yaml:"variants" json:"variants"`
+}
+
+// VariantsConfig defines the rows of a variant picker, e.g. the options of
+// a size select. Selectors are relative to the row; "@attr" reads an
+// attribute of the row itself and an empty Title reads the row's text.
+type VariantsConfig struct {
+	Row       string `yaml:"row" json:"row"`
+	Attribute string `yaml:"attribute" json:"attribute"`
+	Title     string `yaml:"title" json:"title"`
+	SKU       string `yaml:"sku" json:"sku"`
+	Price     string `yaml:"price" json:"price"`
+	Stock     string `yaml:"stock" json:"stock"`
+}
+
+// SpecsConfig defines the rows of a specifications table
+type SpecsConfig struct {
+	Row   string `yaml:"row" json:"row"`
+	Name  string `yaml:"name" json:"name"`
+	Value string `yaml:"value" json:"value"`
+}
+
+// PriceCleanupConfig defines how price text is turned into a number.
+// Separators left empty are guessed from each price.
+type PriceCleanupConfig struct {
+	Remove             []string `yaml:"remove" json:"remove"`
+	ThousandsSeparator string   `yaml:"thousands_separator" json:"thousands_separator"`
+	DecimalSeparator   string   `yaml:"decimal_separator" json:"decimal_separator"`
+}
+
+// SelectorScraper is a scraper driven by a SelectorConfig
+type SelectorScraper struct {
+	config SelectorConfig
+}
+
+// NewSelectorScraper creates a scraper from a retailer definition
+func NewSelectorScraper(config SelectorConfig) (*SelectorScraper, error) {
+	if config.Name == "" {
+		return nil, fmt.Errorf("scraper config is missing a name")
+	}
+	if config.Product.Container == "" || config.Product.Name == "" {
+		return nil, fmt.Errorf("scraper config %s is missing product container or name selectors", config.Name)
+	}
+
+	// Allow the base URL's host when no domains are listed
+	if len(config.AllowedDomains) == 0 && config.BaseURL != "" {
+		u, err := neturl.Parse(config.BaseURL)
+		if err != nil {
+			return nil, fmt.Errorf("parsing base URL of scraper config %s: %w", config.Name, err)
+		}
+		config.AllowedDomains = []string{u.Hostname()}
+	}
+	if len(config.AllowedDomains) == 0 {
+		return nil, fmt.Errorf("scraper config %s has no allowed domains", config.Name)
+	}
+
+	if len(config.Product.OutOfStockText) == 0 {
+		config.Product.OutOfStockText = []string{"out of stock", "sold out"}
+	}
+	if config.Currency == "" {
+		config.Currency = "USD"
+	}
+
+	return &SelectorScraper{config: config}, nil
+}
+
+// LoadSelectorConfig reads a retailer definition from a YAML or JSON file
+func LoadSelectorConfig(path string) (SelectorConfig, error) {
+	var config SelectorConfig
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return config, fmt.Errorf("reading scraper config %s: %w", path, err)
+	}
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		err = json.Unmarshal(data, &config)
+	default:
+		err = yaml.Unmarshal(data, &config)
+	}
+	if err != nil {
+		return config, fmt.Errorf("parsing scraper config %s: %w", path, err)
+	}
+
+	return config, nil
+}
+
+// LoadSelectorScrapers creates a scraper for every YAML and JSON retailer
+// definition in dir
+func LoadSelectorScrapers(dir string) ([]*SelectorScraper, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("reading scraper config directory %s: %w", dir, err)
+	}
+
+	var scrapers []*SelectorScraper
+	for _, entry := range entries {
+		switch strings.ToLower(filepath.Ext(entry.Name())) {
+		case ".yaml", ".yml", ".json":
+		default:
+			continue
+		}
+		if entry.IsDir() {
+			continue
+		}
+
+		config, err := LoadSelectorConfig(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		scraper, err := NewSelectorScraper(config)
+		if err != nil {
+			return nil, fmt.Errorf("loading %s: %w", entry.Name(), err)
+		}
+		scrapers = append(scrapers, scraper)
+	}
+
+	return scrapers, nil
+}
+
+// Name returns the retailer name from the definition
+func (s *SelectorScraper) Name() string {
+	return s.config.Name
+}
+
+// CanHandle checks if the URL is on one of the retailer's allowed domains
+func (s *SelectorScraper) CanHandle(url string) bool {
+	u, err := neturl.Parse(url)
+	if err != nil {
+		return false
+	}
+	for _, domain := range s.config.AllowedDomains {
+		if u.Hostname() == domain {
+			return true
+		}
+	}
+	return false
+}
+
+// Scrape scrapes bike parts using the retailer's selectors
+func (s *SelectorScraper) Scrape(ctx context.Context, req models.ScrapeRequest) (models.ScrapeResult, error) {
+	var parts []models.Part
+	cfg := s.config
+
+	cr, cancel := newCrawl(ctx, req)
+	defer cancel()
+
+	// Initialize the collector
+	c := cr.newCollector(
+		colly.AllowedDomains(cfg.AllowedDomains...),
+	)
+
+	// Follow product tiles and pagination on listing pages
+	if cfg.Listing.Tile != "" {
+		c.OnHTML(cfg.Listing.Tile, func(e *colly.HTMLElement) {
+			link := e.Attr("href")
+			if cfg.Listing.Link != "" {
+				link = e.ChildAttr(cfg.Listing.Link, "href")
+			}
+			if link != "" {
+				cr.visit(e.Request, e.Request.AbsoluteURL(link))
+			}
+		})
+	}
+	if cfg.Listing.Pagination != "" {
+		c.OnHTML(cfg.Listing.Pagination, func(e *colly.HTMLElement) {
+			if link := e.Attr("href"); link != "" {
+				cr.visit(e.Request, e.Request.AbsoluteURL(link))
+			}
+		})
+	}
+
+	// Product page scraper
+	c.OnHTML(cfg.Product.Container, func(e *colly.HTMLElement) {
+		if part, ok := s.parseProduct(e); ok {
+			parts = append(parts, part)
+		}
+	})
+
+	// Start the scraping
+	if err := cr.start(c, req.URL); err != nil {
+		return models.ScrapeResult{}, err
+	}
+
+	// Wait for scraping to finish
+	c.Wait()
+
+	return cr.result(req, parts), nil
+}
+
+// parseProduct reads a part from a product container
+func (s *SelectorScraper) parseProduct(e *colly.HTMLElement) (models.Part, bool) {
+	var part models.Part
+	cfg := s.config.Product
+
+	productName := selectValue(e, cfg.Name)
+	if productName == "" {
+		return part, false
+	}
+
+	part.URL = e.Request.URL.String()
+	part.Source = s.config.Name
+
+	// Use the brand selector if there is one, otherwise the start of the name
+	part.Brand, part.Model = splitBrand(selectValue(e, cfg.Brand), productName)
+
+	// Get the category from breadcrumbs
+	if cfg.Breadcrumbs != "" {
+		setBreadcrumbs(&part, e.ChildTexts(cfg.Breadcrumbs))
+	}
+
+	// Use the first price selector that yields a price
+	for _, selector := range cfg.Price {
+		if price, ok := s.parsePrice(selectValue(e, selector)); ok {
+			part.Price = price.Amount
+			part.Currency = price.Currency
+			break
+		}
+	}
+
+	if msrp, ok := s.parsePrice(selectValue(e, cfg.MSRP)); ok {
+		part.MSRP = msrp.Amount
+		if part.MSRP > 0 {
+			part.Discount = ((part.MSRP - part.Price) / part.MSRP) * 100
+		}
+	}
+
+	// Parts are in stock unless the stock text says otherwise
+	part.InStock = s.inStock(selectValue(e, cfg.Stock))
+
+	part.Description = selectValue(e, cfg.Description)
+
+	if cfg.Images != "" {
+		selector, attr := splitSelector(cfg.Images)
+		if attr == "" {
+			attr = "src"
+		}
+		e.ForEach(selector, func(_ int, el *colly.HTMLElement) {
+			if imgURL := el.Attr(attr); imgURL != "" {
+				part.Images = append(part.Images, e.Request.AbsoluteURL(imgURL))
+			}
+		})
+	}
+
+	if cfg.Specs.Row != "" {
+		e.ForEach(cfg.Specs.Row, func(_ int, el *colly.HTMLElement) {
+			name := selectValue(el, cfg.Specs.Name)
+			value := selectValue(el, cfg.Specs.Value)
+			if name != "" && value != "" {
+				part.Specs = append(part.Specs, models.Spec{
+					Name:  name,
+					Value: value,
+				})
+			}
+		})
+	}
+
+	if cfg.Variants.Row != "" {
+		e.ForEach(cfg.Variants.Row, func(_ int, el *colly.HTMLElement) {
+			if variant, ok := s.parseVariant(el); ok {
+				part.Variants = append(part.Variants, variant)
+			}
+		})
+		summarizeVariants(&part)
+	}
+
+	// Derive a stable ID so re-scrapes update the same part
+	part.SKU = selectValue(e, cfg.SKU)
+	part.MPN = selectValue(e, cfg.MPN)
+	part.GTIN = selectValue(e, cfg.GTIN)
+	identifyPart(&part)
+
+	// Set timestamps
+	now := time.Now()
+	part.CreatedAt = now
+	part.UpdatedAt = now
+
+	return part, true
+}
+
+// parseVariant reads a variant from a row of the variant picker
+func (s *SelectorScraper) parseVariant(el *colly.HTMLElement) (models.Variant, bool) {
+	cfg := s.config.Product.Variants
+
+	title := strings.TrimSpace(el.Text)
+	if cfg.Title != "" {
+		title = selectValue(el, cfg.Title)
+	}
+	if title == "" {
+		return models.Variant{}, false
+	}
+
+	variant := models.Variant{
+		SKU:     selectValue(el, cfg.SKU),
+		InStock: s.inStock(selectValue(el, cfg.Stock)),
+	}
+	if cfg.Attribute != "" {
+		variant.Attributes = map[string]string{cfg.Attribute: title}
+	} else {
+		variant.Title = title
+	}
+	if price, ok := s.parsePrice(selectValue(el, cfg.Price)); ok {
+		variant.Price = price.Amount
+	}
+	return variant, true
+}
+
+// inStock reports whether stock text does not contain any out of stock text
+func (s *SelectorScraper) inStock(stockText string) bool {
+	stockText = strings.ToLower(stockText)
+	for _, text := range s.config.Product.OutOfStockText {
+		if strings.Contains(stockText, strings.ToLower(text)) {
+			return false
+		}
+	}
+	return true
+}
+
+// parsePrice removes the text the price cleanup rules remove and parses
+// the rest with the configured separators and currency
+func (s *SelectorScraper) parsePrice(text string) (Money, bool) {
+	rules := s.config.PriceCleanup
+
+	for _, remove := range rules.Remove {
+		text = strings.ReplaceAll(text, remove, "")
+	}
+
+	format := PriceFormat{
+		Currency:           s.config.Currency,
+		DecimalSeparator:   rules.DecimalSeparator,
+		ThousandsSeparator: rules.ThousandsSeparator,
+	}
+	return format.Parse(text)
+}
+
+// selectValue returns the trimmed text, or attribute for "selector@attr",
+// of the first element matching selector within e, or the attribute of e
+// itself for "@attr"
+func selectValue(e *colly.HTMLElement, selector string) string {
+	if selector == "" {
+		return ""
+	}
+	selector, attr := splitSelector(selector)
+	if selector == "" {
+		return strings.TrimSpace(e.Attr(attr))
+	}
+	if attr != "" {
+		return strings.TrimSpace(e.ChildAttr(selector, attr))
+	}
+	return strings.TrimSpace(e.DOM.Find(selector).First().Text())
+}
+
+// splitSelector splits "selector@attr" into its selector and attribute
+func splitSelector(selector string) (string, string) {
+	if i := strings.LastIndex(selector, "@"); i >= 0 && !strings.ContainsAny(selector[i:], "]) ") {
+		return selector[:i], selector[i+1:]
+	}
+	return selector, ""
+}
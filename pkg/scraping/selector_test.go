@@ -0,0 +1,37 @@
+package scraping_test
+
+import (
+	"testing"
+
+	"github.com/sosadtsia/bike-parts-finder/pkg/scraping"
+	"github.com/sosadtsia/bike-parts-finder/pkg/scraping/scrapetest"
+)
+
+func TestSelectorFixtures(t *testing.T) {
+	scrapetest.Run(t, scrapetest.Case{
+		New: func(string) scraping.Scraper {
+			cfg, err := scraping.LoadSelectorConfig("testdata/selector/retailer.yaml")
+			if err != nil {
+				t.Fatal(err)
+			}
+			s, err := scraping.NewSelectorScraper(cfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			return s
+		},
+		Fixtures: "testdata/selector",
+		Path:     "/c/chains",
+		Golden:   "testdata/selector/chains.golden.json",
+	})
+}
+
+func TestExampleSelectorConfigs(t *testing.T) {
+	scrapers, err := scraping.LoadSelectorScrapers("../../configs/scrapers")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(scrapers) == 0 {
+		t.Error("no example scraper configs loaded")
+	}
+}
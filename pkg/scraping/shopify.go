@@ -0,0 +1,301 @@
+package scraping
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	neturl "net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/sosadtsia/bike-parts-finder/pkg/models"
+)
+
+func init() {
+	register(func() Scraper {
+		return NewShopifyScraper(strings.Split(os.Getenv("SHOPIFY_STORES"), ",")...)
+	})
+}
+
+// shopifyPageSize is the largest page size the products.json endpoint allows
+const shopifyPageSize = 250
+
+// ShopifyScraper is a scraper for Shopify storefronts. It reads the public
+// products.json endpoints instead of parsing HTML.
+type ShopifyScraper struct {
+	domains []string
+	client  *http.Client
+}
+
+// NewShopifyScraper creates a new Shopify scraper for the given store
+// domains. Stores on a *.myshopify.com domain are always handled.
+func NewShopifyScraper(domains ...string) *ShopifyScraper {
+	s := &ShopifyScraper{
+		client: &http.Client{Timeout: 30 * time.Second, Transport: politeTransport{}},
+	}
+	for _, domain := range domains {
+		if domain = strings.ToLower(strings.TrimSpace(domain)); domain != "" {
+			s.domains = append(s.domains, domain)
+		}
+	}
+	return s
+}
+
+// Name returns the name of the Shopify scraper
+func (s *ShopifyScraper) Name() string {
+	return "Shopify"
+}
+
+// CanHandle checks if the URL belongs to a configured or myshopify.com store
+func (s *ShopifyScraper) CanHandle(url string) bool {
+	u, err := neturl.Parse(url)
+	if err != nil {
+		return false
+	}
+	host := strings.ToLower(u.Hostname())
+	if strings.HasSuffix(host, ".myshopify.com") {
+		return true
+	}
+	for _, domain := range s.domains {
+		if host == domain || host == "www."+domain {
+			return true
+		}
+	}
+	return false
+}
+
+// shopifyProduct is a product as returned by the products.json endpoints
+type shopifyProduct struct {
+	ID          int64            `json:"id"`
+	Title       string           `json:"title"`
+	Handle      string           `json:"handle"`
+	BodyHTML    string           `json:"body_html"`
+	Vendor      string           `json:"vendor"`
+	ProductType string           `json:"product_type"`
+	Options     []shopifyOption  `json:"options"`
+	Variants    []shopifyVariant `json:"variants"`
+	Images      []shopifyImage   `json:"images"`
+}
+
+// shopifyOption is a product option such as size or colour
+type shopifyOption struct {
+	Name     string `json:"name"`
+	Position int    `json:"position"`
+}
+
+// shopifyVariant is a purchasable variant of a product
+type shopifyVariant struct {
+	ID             int64         `json:"id"`
+	Title          string        `json:"title"`
+	SKU            string        `json:"sku"`
+	Barcode        string        `json:"barcode"`
+	Price          string        `json:"price"`
+	CompareAtPrice string        `json:"compare_at_price"`
+	Available      bool          `json:"available"`
+	Option1        string        `json:"option1"`
+	Option2        string        `json:"option2"`
+	Option3        string        `json:"option3"`
+	FeaturedImage  *shopifyImage `json:"featured_image"`
+}
+
+// shopifyImage is a product or variant image
+type shopifyImage struct {
+	Src string `json:"src"`
+}
+
+// Scrape scrapes a Shopify store, a collection or a single product,
+// depending on the request URL
+func (s *ShopifyScraper) Scrape(ctx context.Context, req models.ScrapeRequest) (models.ScrapeResult, error) {
+	u, err := neturl.Parse(req.URL)
+	if err != nil {
+		return models.ScrapeResult{}, fmt.Errorf("parsing URL %s: %w", req.URL, err)
+	}
+	base := &neturl.URL{Scheme: u.Scheme, Host: u.Host}
+	source := strings.TrimPrefix(u.Hostname(), "www.")
+
+	cr, cancel := newCrawl(ctx, req)
+	defer cancel()
+
+	currency := s.storeCurrency(cr, base)
+
+	var products []shopifyProduct
+	if handle, ok := shopifyPathValue(u.Path, "products"); ok {
+		// Product pages have a JSON representation at the same path
+		var body struct {
+			Product shopifyProduct `json:"product"`
+		}
+		productURL := base.JoinPath("products", handle+".json").String()
+		if cr.acquirePage() {
+			err := cr.do(productURL, func() error { return s.getJSON(cr.ctx, productURL, &body) })
+			if err == nil {
+				products = append(products, body.Product)
+			}
+		}
+	} else {
+		endpoint := base.JoinPath("products.json")
+		if collection, ok := shopifyPathValue(u.Path, "collections"); ok {
+			endpoint = base.JoinPath("collections", collection, "products.json")
+		}
+
+		for page := 1; cr.acquirePage(); page++ {
+			pageURL := *endpoint
+			pageURL.RawQuery = neturl.Values{
+				"limit": {strconv.Itoa(shopifyPageSize)},
+				"page":  {strconv.Itoa(page)},
+			}.Encode()
+
+			// Later pages cannot be reached once a page has failed
+			var body struct {
+				Products []shopifyProduct `json:"products"`
+			}
+			if err := cr.do(pageURL.String(), func() error { return s.getJSON(cr.ctx, pageURL.String(), &body) }); err != nil {
+				break
+			}
+
+			products = append(products, body.Products...)
+			if len(body.Products) < shopifyPageSize {
+				break
+			}
+		}
+	}
+
+	var parts []models.Part
+	for _, product := range products {
+		parts = append(parts, shopifyPart(product, base, source, currency))
+	}
+
+	return cr.result(req, parts), nil
+}
+
+// storeCurrency returns the store's currency from its cart endpoint,
+// falling back to USD when it cannot be read
+func (s *ShopifyScraper) storeCurrency(cr *crawl, base *neturl.URL) string {
+	var cart struct {
+		Currency string `json:"currency"`
+	}
+	if err := s.getJSON(cr.ctx, base.JoinPath("cart.js").String(), &cart); err != nil || cart.Currency == "" {
+		return "USD"
+	}
+	return strings.ToUpper(cart.Currency)
+}
+
+// getJSON fetches url and decodes the JSON response into v
+func (s *ShopifyScraper) getJSON(ctx context.Context, url string, v any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return &StatusError{URL: url, StatusCode: resp.StatusCode}
+	}
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return &ParseError{URL: url, Err: err}
+	}
+	return nil
+}
+
+// shopifyPart maps a product to a part with one variant per Shopify
+// variant. Products with only the default variant have no variants.
+func shopifyPart(product shopifyProduct, base *neturl.URL, source, currency string) models.Part {
+	var part models.Part
+
+	part.Source = source
+	part.Brand, part.Model = splitBrand(product.Vendor, product.Title)
+	setBreadcrumbs(&part, []string{product.ProductType})
+	part.Currency = currency
+	part.URL = base.JoinPath("products", product.Handle).String()
+
+	part.Description = product.BodyHTML
+	if doc, err := goquery.NewDocumentFromReader(strings.NewReader(product.BodyHTML)); err == nil {
+		part.Description = strings.TrimSpace(doc.Text())
+	}
+
+	for _, img := range product.Images {
+		part.Images = append(part.Images, img.Src)
+	}
+
+	// Shopify prices are decimal strings in the store's currency
+	format := PriceFormat{Currency: currency, DecimalSeparator: "."}
+	for _, v := range product.Variants {
+		variant := models.Variant{
+			SKU:     v.SKU,
+			GTIN:    v.Barcode,
+			InStock: v.Available,
+		}
+		if v.Title != "Default Title" {
+			variant.Title = v.Title
+		}
+		if price, ok := format.Parse(v.Price); ok {
+			variant.Price = price.Amount
+		}
+		if msrp, ok := format.Parse(v.CompareAtPrice); ok && msrp.Amount > variant.Price {
+			variant.MSRP = msrp.Amount
+		}
+		if v.FeaturedImage != nil {
+			variant.Image = v.FeaturedImage.Src
+		}
+
+		variantURL := base.JoinPath("products", product.Handle)
+		variantURL.RawQuery = "variant=" + strconv.FormatInt(v.ID, 10)
+		variant.URL = variantURL.String()
+
+		// Record the variant's option values, e.g. Size: 29"
+		for i, value := range []string{v.Option1, v.Option2, v.Option3} {
+			if value == "" || value == "Default Title" || i >= len(product.Options) {
+				continue
+			}
+			if variant.Attributes == nil {
+				variant.Attributes = make(map[string]string)
+			}
+			variant.Attributes[product.Options[i].Name] = value
+		}
+
+		part.Variants = append(part.Variants, variant)
+	}
+
+	// A product without options is sold as is
+	if len(part.Variants) == 1 && len(part.Variants[0].Attributes) == 0 {
+		only := part.Variants[0]
+		part.Variants = nil
+		part.SKU = only.SKU
+		part.GTIN = only.GTIN
+		part.Price = only.Price
+		part.MSRP = only.MSRP
+		if part.MSRP > part.Price {
+			part.Discount = ((part.MSRP - part.Price) / part.MSRP) * 100
+		}
+		part.InStock = only.InStock
+	}
+	summarizeVariants(&part)
+	identifyPart(&part)
+
+	now := time.Now()
+	part.CreatedAt = now
+	part.UpdatedAt = now
+
+	return part
+}
+
+// shopifyPathValue returns the path segment following segment, e.g. the
+// collection handle of /collections/forks
+func shopifyPathValue(path, segment string) (string, bool) {
+	fields := strings.Split(strings.Trim(path, "/"), "/")
+	for i := 0; i < len(fields)-1; i++ {
+		if fields[i] == segment && fields[i+1] != "" {
+			return strings.TrimSuffix(fields[i+1], ".json"), true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,17 @@
+package scraping_test
+
+import (
+	"testing"
+
+	"github.com/sosadtsia/bike-parts-finder/pkg/scraping"
+	"github.com/sosadtsia/bike-parts-finder/pkg/scraping/scrapetest"
+)
+
+func TestShopifyFixtures(t *testing.T) {
+	scrapetest.Run(t, scrapetest.Case{
+		New:      func(string) scraping.Scraper { return scraping.NewShopifyScraper() },
+		Fixtures: "testdata/shopify",
+		Path:     "/collections/forks",
+		Golden:   "testdata/shopify/forks.golden.json",
+	})
+}
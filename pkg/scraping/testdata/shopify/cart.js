@@ -0,0 +1 @@
+{"token":"fixture","note":null,"attributes":{},"item_count":0,"items":[],"currency":"CAD"}
@@ -0,0 +1,59 @@
+package scraping
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/sosadtsia/bike-parts-finder/pkg/models"
+)
+
+// summarizeVariants sets the part's price, MSRP and stock from its
+// variants: the price of the cheapest priced variant and in stock when any
+// variant is. The part's MSRP is kept when variants do not have their own.
+// Variants without a title are named after their attributes.
+func summarizeVariants(part *models.Part) {
+	if len(part.Variants) == 0 {
+		return
+	}
+
+	var cheapest *models.Variant
+	part.InStock = false
+	for i := range part.Variants {
+		variant := &part.Variants[i]
+		if variant.Title == "" {
+			variant.Title = variantTitle(variant.Attributes)
+		}
+		if variant.InStock {
+			part.InStock = true
+		}
+		if variant.Price > 0 && (cheapest == nil || variant.Price < cheapest.Price) {
+			cheapest = variant
+		}
+	}
+
+	if cheapest != nil {
+		part.Price = cheapest.Price
+		if cheapest.MSRP > 0 {
+			part.MSRP = cheapest.MSRP
+		}
+		part.Discount = 0
+		if part.MSRP > part.Price {
+			part.Discount = ((part.MSRP - part.Price) / part.MSRP) * 100
+		}
+	}
+}
+
+// variantTitle joins attribute values in name order, e.g. "Black / 29"
+func variantTitle(attributes map[string]string) string {
+	names := make([]string, 0, len(attributes))
+	for name := range attributes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	values := make([]string, 0, len(names))
+	for _, name := range names {
+		values = append(values, attributes[name])
+	}
+	return strings.Join(values, " / ")
+}
@@ -0,0 +1,278 @@
+package specs
+
+import (
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/sosadtsia/bike-parts-finder/pkg/models"
+)
+
+// Canonical units of numeric spec values
+const (
+	UnitGrams       = "g"
+	UnitMillimetres = "mm"
+	UnitInches      = "in"
+	UnitTeeth       = "teeth"
+	UnitSpeeds      = "speeds"
+	UnitCount       = "count"
+)
+
+// Attribute is a canonical spec attribute with the names retailers use for
+// it and the unit its values are converted to
+type Attribute struct {
+	Key   string
+	Unit  string
+	Names []string
+	parse func(value string) (float64, bool)
+}
+
+// Attributes are the spec attributes values are parsed for. Specs with
+// other names keep only their raw text under a key derived from the name.
+// Names are specific enough not to catch unrelated specs: a bare "range"
+// may be a price or battery range and "gearing" a ratio.
+var Attributes = []Attribute{
+	{Key: "weight", Unit: UnitGrams, parse: parseMass,
+		Names: []string{"weight", "claimed weight", "actual weight", "weight claimed", "approx weight"}},
+	{Key: "travel", Unit: UnitMillimetres, parse: parseLength,
+		Names: []string{"travel", "fork travel", "rear travel", "suspension travel", "stroke travel", "dropper travel"}},
+	{Key: "wheel_size", Unit: UnitInches, parse: parseWheelSize,
+		Names: []string{"wheel size", "wheel diameter", "tire size", "tyre size", "rim size"}},
+	{Key: "speeds", Unit: UnitSpeeds, parse: parseSpeeds,
+		Names: []string{"speed", "speeds", "number of speeds", "drivetrain speed"}},
+	{Key: "teeth", Unit: UnitTeeth, parse: parseTeeth,
+		Names: []string{"teeth", "tooth count", "chainring size", "chainring", "chainrings", "cog size"}},
+	{Key: "cassette_range", Unit: UnitTeeth, parse: parseTeeth,
+		Names: []string{"cassette range", "gear range", "cog range"}},
+	{Key: "rotor_size", Unit: UnitMillimetres, parse: parseLength,
+		Names: []string{"rotor size", "rotor diameter", "rotor"}},
+	{Key: "width", Unit: UnitMillimetres, parse: parseLength,
+		Names: []string{"width", "bar width", "tire width", "tyre width", "internal width", "inner width"}},
+	{Key: "length", Unit: UnitMillimetres, parse: parseLength,
+		Names: []string{"length", "stem length", "crank length", "crank arm length", "chain length"}},
+	{Key: "diameter", Unit: UnitMillimetres, parse: parseLength,
+		Names: []string{"diameter", "seatpost diameter", "clamp diameter", "bar clamp diameter", "handlebar clamp"}},
+	{Key: "pistons", Unit: UnitCount, parse: parseCount,
+		Names: []string{"pistons", "number of pistons", "piston count"}},
+}
+
+// byName indexes Attributes by their folded names
+var byName = func() map[string]*Attribute {
+	m := make(map[string]*Attribute)
+	for i := range Attributes {
+		attr := &Attributes[i]
+		for _, name := range append([]string{attr.Key}, attr.Names...) {
+			m[foldName(name)] = attr
+		}
+	}
+	return m
+}()
+
+// Lookup returns the attribute with the given key
+func Lookup(key string) (Attribute, bool) {
+	for _, attr := range Attributes {
+		if attr.Key == key {
+			return attr, true
+		}
+	}
+	return Attribute{}, false
+}
+
+// Normalize sets the canonical key of a spec and, for known attributes,
+// its numeric value in the attribute's unit. The raw name and value are
+// kept unchanged.
+func Normalize(spec models.Spec) models.Spec {
+	spec.Key, spec.NumericValue, spec.Unit = "", nil, ""
+
+	attr, ok := Resolve(spec.Name)
+	if !ok {
+		spec.Key = KeyFor(spec.Name)
+		return spec
+	}
+
+	spec.Key = attr.Key
+	if value, ok := attr.Parse(spec.Value); ok {
+		spec.NumericValue = &value
+		spec.Unit = attr.Unit
+	}
+	return spec
+}
+
+// Parse parses a value of the attribute into its unit, rounded like the
+// numeric values of normalized specs
+func (a Attribute) Parse(value string) (float64, bool) {
+	n, ok := a.parse(value)
+	if !ok {
+		return 0, false
+	}
+	return math.Round(n*100) / 100, true
+}
+
+// Resolve returns the attribute a spec name or key stands for, so that
+// e.g. "speed" and "number of speeds" both resolve to speeds
+func Resolve(name string) (Attribute, bool) {
+	if attr, ok := byName[foldName(name)]; ok {
+		return *attr, true
+	}
+	return Attribute{}, false
+}
+
+// KeyFor returns the key that specs with a name are stored under: the
+// attribute's key, or for other specs a key derived from the name
+func KeyFor(name string) string {
+	if attr, ok := Resolve(name); ok {
+		return attr.Key
+	}
+	return strings.ReplaceAll(foldName(name), " ", "_")
+}
+
+// NormalizeAll normalises every spec of a part
+func NormalizeAll(specs []models.Spec) []models.Spec {
+	for i := range specs {
+		specs[i] = Normalize(specs[i])
+	}
+	return specs
+}
+
+// foldName lowercases a spec name and reduces it to words, dropping
+// punctuation and parenthesised remarks such as "Weight (claimed)"
+func foldName(name string) string {
+	if i := strings.Index(name, "("); i > 0 {
+		name = name[:i]
+	}
+	return strings.Join(strings.FieldsFunc(strings.ToLower(name), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}), " ")
+}
+
+// quantityPattern matches a number and the unit that follows it
+var quantityPattern = regexp.MustCompile(`(\d+(?:[.,]\d+)*)\s*(kg|g|grams?|lbs?|pounds?|oz|ounces?|mm|cm|m|inch(?:es)?|in|"|”|″|'')?`)
+
+// quantity returns the first number in a value and its lowercased unit
+func quantity(value string) (float64, string, bool) {
+	match := quantityPattern.FindStringSubmatch(strings.ToLower(value))
+	if match == nil {
+		return 0, "", false
+	}
+	number, ok := parseNumber(match[1])
+	return number, match[2], ok
+}
+
+// parseNumber parses a number that may use a comma as decimal separator or
+// to group thousands, e.g. "2,4" or "1,250"
+func parseNumber(s string) (float64, bool) {
+	if i := strings.LastIndex(s, ","); i >= 0 {
+		if len(s)-i-1 == 3 && !strings.Contains(s, ".") {
+			s = strings.ReplaceAll(s, ",", "")
+		} else {
+			s = strings.ReplaceAll(s, ",", ".")
+		}
+	}
+	n, err := strconv.ParseFloat(s, 64)
+	return n, err == nil
+}
+
+// parseMass parses a weight into grams, assuming grams without a unit
+func parseMass(value string) (float64, bool) {
+	n, unit, ok := quantity(value)
+	if !ok {
+		return 0, false
+	}
+	switch {
+	case unit == "kg":
+		return n * 1000, true
+	case strings.HasPrefix(unit, "lb"), strings.HasPrefix(unit, "pound"):
+		return n * 453.59237, true
+	case unit == "oz", strings.HasPrefix(unit, "ounce"):
+		return n * 28.349523125, true
+	case unit == "" || strings.HasPrefix(unit, "g"):
+		return n, true
+	}
+	return 0, false
+}
+
+// parseLength parses a length into millimetres, assuming millimetres
+// without a unit
+func parseLength(value string) (float64, bool) {
+	n, unit, ok := quantity(value)
+	if !ok {
+		return 0, false
+	}
+	switch unit {
+	case "", "mm":
+		return n, true
+	case "cm":
+		return n * 10, true
+	case "m":
+		return n * 1000, true
+	case "in", "inch", "inches", `"`, "”", "″", "''":
+		return n * 25.4, true
+	}
+	return 0, false
+}
+
+// wheelSizes maps road and French wheel size designations to inches
+var wheelSizes = map[string]float64{
+	"700c": 29, // ISO 622, the same rim as a 29er
+	"700":  29,
+	"650b": 27.5,
+	"29er": 29,
+}
+
+// parseWheelSize parses a wheel size in inches such as 29", 27.5 or 700c
+func parseWheelSize(value string) (float64, bool) {
+	lower := strings.ToLower(value)
+	for _, field := range strings.FieldsFunc(lower, func(r rune) bool { return unicode.IsSpace(r) || r == '/' || r == ',' }) {
+		if size, ok := wheelSizes[field]; ok {
+			return size, true
+		}
+	}
+	n, unit, ok := quantity(lower)
+	if !ok || (unit != "" && unit != "in" && unit != "inch" && unit != "inches" && unit != `"` && unit != "”" && unit != "″" && unit != "''") {
+		return 0, false
+	}
+	// Only plausible wheel sizes; anything else is e.g. an ETRTO width
+	if n < 12 || n > 36 {
+		return 0, false
+	}
+	return n, true
+}
+
+// drivetrainPattern matches a drivetrain layout such as 1x12
+var drivetrainPattern = regexp.MustCompile(`\d\s*x\s*(\d+)`)
+
+// parseSpeeds parses a number of speeds such as "12-speed" or "1x12"
+func parseSpeeds(value string) (float64, bool) {
+	if match := drivetrainPattern.FindStringSubmatch(strings.ToLower(value)); match != nil {
+		return parseNumber(match[1])
+	}
+	return parseCount(value)
+}
+
+// rangePattern matches a range of tooth counts such as 10-51T
+var rangePattern = regexp.MustCompile(`(\d+)\s*[-–/]\s*(\d+)`)
+
+// parseTeeth parses a tooth count such as 32T; for a range such as 10-51T
+// the largest count is used
+func parseTeeth(value string) (float64, bool) {
+	if match := rangePattern.FindStringSubmatch(value); match != nil {
+		low, _ := parseNumber(match[1])
+		high, _ := parseNumber(match[2])
+		return math.Max(low, high), true
+	}
+	return parseCount(value)
+}
+
+// countPattern matches a whole number
+var countPattern = regexp.MustCompile(`\d+`)
+
+// parseCount parses the first whole number of a value
+func parseCount(value string) (float64, bool) {
+	match := countPattern.FindString(value)
+	if match == "" {
+		return 0, false
+	}
+	return parseNumber(match)
+}
@@ -0,0 +1,148 @@
+package specs
+
+import (
+	"math"
+	"testing"
+
+	"github.com/sosadtsia/bike-parts-finder/pkg/models"
+)
+
+// parserTest is a value, the number it parses to and whether it parses
+type parserTest struct {
+	value string
+	want  float64
+	ok    bool
+}
+
+// testParser runs a parser over table tests
+func testParser(t *testing.T, name string, parse func(string) (float64, bool), tests []parserTest) {
+	t.Helper()
+	for _, tt := range tests {
+		got, ok := parse(tt.value)
+		if ok != tt.ok || math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("%s(%q) = %v, %v; want %v, %v", name, tt.value, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestParseMass(t *testing.T) {
+	testParser(t, "parseMass", parseMass, []parserTest{
+		{"460g", 460, true},
+		{"460 grams", 460, true},
+		{"460", 460, true},
+		{"1.2 kg", 1200, true},
+		{"2,4kg", 2400, true},
+		{"1,250 g", 1250, true},
+		{"1 lb", 453.59237, true},
+		{"2 lbs", 907.18474, true},
+		{"8 oz", 226.796185, true},
+		{"1.5 ounces", 42.5242846875, true},
+		{"380g (27.5\")", 380, true},
+		{"5 mm", 0, false},
+		{"heavy", 0, false},
+	})
+}
+
+func TestParseLength(t *testing.T) {
+	testParser(t, "parseLength", parseLength, []parserTest{
+		{"140mm", 140, true},
+		{"170", 170, true},
+		{"14 cm", 140, true},
+		{"0.8 m", 800, true},
+		{"5.5in", 139.7, true},
+		{"2.4\"", 60.96, true},
+		{"1 inch", 25.4, true},
+		{"31,6 mm", 31.6, true},
+		{"5 kg", 0, false},
+		{"long", 0, false},
+	})
+}
+
+func TestParseWheelSize(t *testing.T) {
+	testParser(t, "parseWheelSize", parseWheelSize, []parserTest{
+		{"29\"", 29, true},
+		{"27.5", 27.5, true},
+		{"26 inch", 26, true},
+		{"700c", 29, true},
+		{"700 x 25c", 29, true},
+		{"650B", 27.5, true},
+		{"29er", 29, true},
+		{"29 mm", 0, false},
+		{"2.4", 0, false},
+		{"622", 0, false},
+	})
+}
+
+func TestParseSpeeds(t *testing.T) {
+	testParser(t, "parseSpeeds", parseSpeeds, []parserTest{
+		{"12", 12, true},
+		{"12-speed", 12, true},
+		{"1x12", 12, true},
+		{"2 x 11", 11, true},
+		{"single", 0, false},
+	})
+}
+
+func TestParseTeeth(t *testing.T) {
+	testParser(t, "parseTeeth", parseTeeth, []parserTest{
+		{"32T", 32, true},
+		{"10-51T", 51, true},
+		{"11–46", 46, true},
+		{"51/10", 51, true},
+		{"oval", 0, false},
+	})
+}
+
+func TestResolve(t *testing.T) {
+	tests := []struct {
+		name string
+		key  string
+	}{
+		{"Weight", "weight"},
+		{"Weight (claimed)", "weight"},
+		{"Claimed Weight:", "weight"},
+		{"Number of Speeds", "speeds"},
+		{"Cassette Range", "cassette_range"},
+		{"speeds", "speeds"},
+		// Broad names are not taken for an attribute
+		{"Range", ""},
+		{"Gearing", ""},
+		{"Colour", ""},
+	}
+	for _, tt := range tests {
+		attr, ok := Resolve(tt.name)
+		if attr.Key != tt.key || ok != (tt.key != "") {
+			t.Errorf("Resolve(%q) = %q, %v; want %q", tt.name, attr.Key, ok, tt.key)
+		}
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		spec    models.Spec
+		key     string
+		value   float64
+		unit    string
+		numeric bool
+	}{
+		{models.Spec{Name: "Weight", Value: "1 lb"}, "weight", 453.59, UnitGrams, true},
+		{models.Spec{Name: "Rotor Size", Value: "8\""}, "rotor_size", 203.2, UnitMillimetres, true},
+		{models.Spec{Name: "Wheel Size", Value: "700c"}, "wheel_size", 29, UnitInches, true},
+		{models.Spec{Name: "Travel", Value: "adjustable"}, "travel", 0, "", false},
+		{models.Spec{Name: "Gearing", Value: "34/50"}, "gearing", 0, "", false},
+		{models.Spec{Name: "Frame Material", Value: "Carbon"}, "frame_material", 0, "", false},
+	}
+	for _, tt := range tests {
+		spec := Normalize(tt.spec)
+		if spec.Key != tt.key || spec.Unit != tt.unit || (spec.NumericValue != nil) != tt.numeric {
+			t.Errorf("Normalize(%+v) = %+v, want key %q unit %q", tt.spec, spec, tt.key, tt.unit)
+			continue
+		}
+		if tt.numeric && *spec.NumericValue != tt.value {
+			t.Errorf("Normalize(%+v) value %v, want %v", tt.spec, *spec.NumericValue, tt.value)
+		}
+		if spec.Name != tt.spec.Name || spec.Value != tt.spec.Value {
+			t.Errorf("Normalize(%+v) changed the raw text to %q: %q", tt.spec, spec.Name, spec.Value)
+		}
+	}
+}
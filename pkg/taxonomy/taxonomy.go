@@ -0,0 +1,174 @@
+package taxonomy
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/sosadtsia/bike-parts-finder/pkg/models"
+)
+
+// AnySource is the mapping source that applies to every retailer
+const AnySource = "*"
+
+// Mapper maps retailer breadcrumbs to nodes of the canonical taxonomy
+type Mapper struct {
+	categories map[string]models.Category
+	rules      []rule
+}
+
+// rule is a mapping with its breadcrumbs folded for matching
+type rule struct {
+	source     string
+	crumbs     []string
+	categoryID string
+}
+
+// NewMapper creates a mapper from the taxonomy and its mapping rules.
+// Rules pointing to unknown categories are ignored.
+func NewMapper(categories []models.Category, mappings []models.CategoryMapping) *Mapper {
+	m := &Mapper{categories: make(map[string]models.Category)}
+	for _, category := range categories {
+		m.categories[category.ID] = category
+	}
+
+	for _, mapping := range mappings {
+		if _, ok := m.categories[mapping.CategoryID]; !ok || len(mapping.Breadcrumbs) == 0 {
+			continue
+		}
+		r := rule{source: strings.ToLower(mapping.Source), categoryID: mapping.CategoryID}
+		for _, crumb := range mapping.Breadcrumbs {
+			r.crumbs = append(r.crumbs, crumbKey(crumb))
+		}
+		m.rules = append(m.rules, r)
+	}
+
+	return m
+}
+
+// Map returns the taxonomy node for a retailer's breadcrumbs. Of the rules
+// that match, the one matching deepest in the path wins, then the longest
+// rule, then a rule for the source over one for any source.
+func (m *Mapper) Map(source string, breadcrumbs []string) (models.Category, bool) {
+	source = strings.ToLower(source)
+	crumbs := make([]string, len(breadcrumbs))
+	for i, crumb := range breadcrumbs {
+		crumbs[i] = crumbKey(crumb)
+	}
+
+	var best *rule
+	var bestEnd int
+	for i := range m.rules {
+		r := &m.rules[i]
+		if r.source != source && r.source != AnySource {
+			continue
+		}
+		end, ok := matchEnd(crumbs, r.crumbs)
+		if !ok {
+			continue
+		}
+		if best == nil || end > bestEnd ||
+			(end == bestEnd && len(r.crumbs) > len(best.crumbs)) ||
+			(end == bestEnd && len(r.crumbs) == len(best.crumbs) && best.source == AnySource && r.source != AnySource) {
+			best, bestEnd = r, end
+		}
+	}
+
+	if best == nil {
+		return models.Category{}, false
+	}
+	return m.categories[best.categoryID], true
+}
+
+// Apply maps the part's breadcrumbs and replaces its category names with
+// the taxonomy's: Category becomes the top-level node and SubCategory the
+// mapped node below it. Parts that cannot be mapped keep the retailer's
+// names and false is returned.
+func (m *Mapper) Apply(part *models.Part) bool {
+	category, ok := m.Map(part.Source, part.Breadcrumbs)
+	if !ok {
+		return false
+	}
+
+	part.CategoryID = category.ID
+	root := category
+	for root.ParentID != "" {
+		parent, ok := m.categories[root.ParentID]
+		if !ok {
+			break
+		}
+		root = parent
+	}
+	part.Category = root.Name
+	part.SubCategory = ""
+	if root.ID != category.ID {
+		part.SubCategory = category.Name
+	}
+	return true
+}
+
+// Mapped reports whether any rule matches the breadcrumbs of a source
+func (m *Mapper) Mapped(source string, breadcrumbs []string) bool {
+	_, ok := m.Map(source, breadcrumbs)
+	return ok
+}
+
+// matchEnd returns the position after the last occurrence of pattern as a
+// consecutive run in crumbs
+func matchEnd(crumbs, pattern []string) (int, bool) {
+	for start := len(crumbs) - len(pattern); start >= 0; start-- {
+		match := true
+		for i, crumb := range pattern {
+			if crumbs[start+i] != crumb {
+				match = false
+				break
+			}
+		}
+		if match {
+			return start + len(pattern), true
+		}
+	}
+	return 0, false
+}
+
+// crumbKey folds a breadcrumb for matching, ignoring case, spacing and
+// punctuation, so "Disc Brakes", "disc-brakes" and "Disc  Brakes" match
+func crumbKey(crumb string) string {
+	crumb = strings.ReplaceAll(crumb, "&", "and")
+	return strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return unicode.ToLower(r)
+		}
+		return -1
+	}, crumb)
+}
+
+// Tree nests a flat list of categories under their parents, ordering
+// siblings by position and name. Part counts of children are added to
+// their ancestors.
+func Tree(categories []models.Category) []models.Category {
+	children := make(map[string][]models.Category)
+	for _, category := range categories {
+		children[category.ParentID] = append(children[category.ParentID], category)
+	}
+
+	var build func(parentID string) []models.Category
+	build = func(parentID string) []models.Category {
+		nodes := children[parentID]
+		sort.SliceStable(nodes, func(i, j int) bool {
+			if nodes[i].Position != nodes[j].Position {
+				return nodes[i].Position < nodes[j].Position
+			}
+			return nodes[i].Name < nodes[j].Name
+		})
+		for i := range nodes {
+			nodes[i].Children = build(nodes[i].ID)
+			for _, child := range nodes[i].Children {
+				nodes[i].PartCount += child.PartCount
+			}
+		}
+		return nodes
+	}
+
+	return build("")
+}
@@ -0,0 +1,146 @@
+package taxonomy
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/sosadtsia/bike-parts-finder/pkg/models"
+)
+
+// testCategories is a small taxonomy
+var testCategories = []models.Category{
+	{ID: "brakes", Name: "Brakes"},
+	{ID: "brakes/disc-brakes", Name: "Disc Brakes", ParentID: "brakes"},
+	{ID: "brakes/rotors", Name: "Rotors", ParentID: "brakes"},
+	{ID: "drivetrain", Name: "Drivetrain"},
+	{ID: "drivetrain/cassettes", Name: "Cassettes", ParentID: "drivetrain"},
+	{ID: "drivetrain/chains", Name: "Chains", ParentID: "drivetrain"},
+}
+
+// testMappings has generic rules and rules for JensonUSA
+var testMappings = []models.CategoryMapping{
+	{Source: AnySource, Breadcrumbs: []string{"Brakes"}, CategoryID: "brakes"},
+	{Source: AnySource, Breadcrumbs: []string{"Disc Brakes"}, CategoryID: "brakes/disc-brakes"},
+	{Source: AnySource, Breadcrumbs: []string{"Rotors"}, CategoryID: "brakes/rotors"},
+	{Source: AnySource, Breadcrumbs: []string{"Cassettes"}, CategoryID: "drivetrain/cassettes"},
+	{Source: AnySource, Breadcrumbs: []string{"Chains"}, CategoryID: "drivetrain/chains"},
+	{Source: "JensonUSA", Breadcrumbs: []string{"Chains"}, CategoryID: "drivetrain/cassettes"},
+	{Source: "JensonUSA", Breadcrumbs: []string{"Brakes", "Parts"}, CategoryID: "brakes/rotors"},
+	{Source: AnySource, Breadcrumbs: []string{"Pedals"}, CategoryID: "pedals"},
+}
+
+func TestMap(t *testing.T) {
+	m := NewMapper(testCategories, testMappings)
+
+	tests := []struct {
+		source      string
+		breadcrumbs []string
+		want        string
+	}{
+		// Generic rules apply to every source
+		{"Competitive Cyclist", []string{"Components", "Disc Brakes"}, "brakes/disc-brakes"},
+		{"Competitive Cyclist", []string{"Components", "Chains"}, "drivetrain/chains"},
+		// Rules for the source win over generic rules of the same length,
+		// whatever the case of the source
+		{"JensonUSA", []string{"Components", "Chains"}, "drivetrain/cassettes"},
+		{"jensonusa", []string{"Components", "Chains"}, "drivetrain/cassettes"},
+		// The rule matching deepest in the trail wins
+		{"Competitive Cyclist", []string{"Brakes", "Rotors"}, "brakes/rotors"},
+		{"Competitive Cyclist", []string{"Rotors", "Brakes"}, "brakes"},
+		// Then the longest rule
+		{"JensonUSA", []string{"Components", "Brakes", "Parts"}, "brakes/rotors"},
+		{"Competitive Cyclist", []string{"Components", "Brakes", "Parts"}, "brakes"},
+		// Breadcrumbs match ignoring case, spacing and punctuation
+		{"Competitive Cyclist", []string{"COMPONENTS", "disc-brakes"}, "brakes/disc-brakes"},
+		// Unmapped trails and rules to unknown categories
+		{"Competitive Cyclist", []string{"Components", "Saddles"}, ""},
+		{"Competitive Cyclist", []string{"Pedals"}, ""},
+		{"Competitive Cyclist", nil, ""},
+	}
+	for _, tt := range tests {
+		category, ok := m.Map(tt.source, tt.breadcrumbs)
+		if category.ID != tt.want || ok != (tt.want != "") {
+			t.Errorf("Map(%q, %q) = %q, %v; want %q", tt.source, tt.breadcrumbs, category.ID, ok, tt.want)
+		}
+	}
+}
+
+func TestMatchEnd(t *testing.T) {
+	crumbs := []string{"components", "brakes", "parts", "brakes"}
+
+	tests := []struct {
+		pattern []string
+		end     int
+		ok      bool
+	}{
+		{[]string{"brakes"}, 4, true},
+		{[]string{"brakes", "parts"}, 3, true},
+		{[]string{"components", "brakes"}, 2, true},
+		{[]string{"components", "parts"}, 0, false},
+		{[]string{"brakes", "parts", "brakes", "pads"}, 0, false},
+		{[]string{"components", "brakes", "parts", "brakes", "pads"}, 0, false},
+	}
+	for _, tt := range tests {
+		end, ok := matchEnd(crumbs, tt.pattern)
+		if end != tt.end || ok != tt.ok {
+			t.Errorf("matchEnd(%q) = %d, %v; want %d, %v", tt.pattern, end, ok, tt.end, tt.ok)
+		}
+	}
+}
+
+func TestApply(t *testing.T) {
+	m := NewMapper(testCategories, testMappings)
+
+	part := models.Part{Source: "JensonUSA", Breadcrumbs: []string{"Parts", "Disc Brakes"}, Category: "Parts"}
+	if !m.Apply(&part) {
+		t.Fatal("part was not mapped")
+	}
+	if part.CategoryID != "brakes/disc-brakes" || part.Category != "Brakes" || part.SubCategory != "Disc Brakes" {
+		t.Errorf("got %q %q / %q", part.CategoryID, part.Category, part.SubCategory)
+	}
+
+	// Top-level nodes have no sub-category
+	part = models.Part{Source: "JensonUSA", Breadcrumbs: []string{"Brakes"}, SubCategory: "Brakes"}
+	if !m.Apply(&part) || part.Category != "Brakes" || part.SubCategory != "" {
+		t.Errorf("got %q / %q, want Brakes without a sub-category", part.Category, part.SubCategory)
+	}
+
+	// Unmapped parts keep the retailer's names
+	part = models.Part{Source: "JensonUSA", Breadcrumbs: []string{"Saddles"}, Category: "Saddles"}
+	if m.Apply(&part) || part.CategoryID != "" || part.Category != "Saddles" {
+		t.Errorf("got %+v, want the part unchanged", part)
+	}
+}
+
+func TestTree(t *testing.T) {
+	tree := Tree([]models.Category{
+		{ID: "drivetrain/chains", Name: "Chains", ParentID: "drivetrain", PartCount: 2},
+		{ID: "drivetrain", Name: "Drivetrain", PartCount: 1},
+		{ID: "drivetrain/cassettes", Name: "Cassettes", ParentID: "drivetrain", PartCount: 4},
+		{ID: "drivetrain/cassettes/road", Name: "Road", ParentID: "drivetrain/cassettes", PartCount: 3},
+		{ID: "wheels", Name: "Wheels"},
+		{ID: "brakes", Name: "Brakes", Position: 1},
+	})
+
+	var roots []string
+	for _, node := range tree {
+		roots = append(roots, node.ID)
+	}
+	// Siblings are ordered by position, then name
+	if want := []string{"drivetrain", "wheels", "brakes"}; !slices.Equal(roots, want) {
+		t.Fatalf("got roots %v, want %v", roots, want)
+	}
+
+	drivetrain := tree[0]
+	if len(drivetrain.Children) != 2 || drivetrain.Children[0].ID != "drivetrain/cassettes" {
+		t.Fatalf("got children %+v, want cassettes then chains", drivetrain.Children)
+	}
+	// Part counts include every descendant
+	if drivetrain.PartCount != 10 || drivetrain.Children[0].PartCount != 7 {
+		t.Errorf("got counts %d and %d, want 10 and 7", drivetrain.PartCount, drivetrain.Children[0].PartCount)
+	}
+
+	if tree := Tree(nil); len(tree) != 0 {
+		t.Errorf("got %+v for no categories", tree)
+	}
+}
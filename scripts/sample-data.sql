@@ -0,0 +1,48 @@
+-- Sample data for local development. Apply the schema migrations first,
+-- then run this file with "api migrate seed scripts/sample-data.sql"; it
+-- can be run again safely.
+
+INSERT INTO parts (id, brand, model, category, sub_category, category_id, price, msrp, currency, in_stock, description, url, source)
+VALUES
+    ('1', 'Shimano', 'Deore XT M8100 12-speed Cassette', 'Drivetrain', 'Cassettes', 'drivetrain/cassettes', 159.99, 179.99, 'USD', TRUE, 'High-performance 12-speed cassette for mountain bikes.', 'https://example.com/shimano-xt-cassette', 'Sample'),
+    ('2', 'SRAM', 'GX Eagle Derailleur', 'Drivetrain', 'Derailleurs', 'drivetrain/derailleurs', 129.99, 149.99, 'USD', TRUE, '12-speed rear derailleur with Eagle technology.', 'https://example.com/sram-gx-eagle', 'Sample'),
+    ('3', 'RockShox', 'Pike Ultimate Fork', 'Suspension', 'Forks', 'suspension/forks', 899.99, 949.99, 'USD', FALSE, 'Premium trail fork with Charger 2.1 damper.', 'https://example.com/rockshox-pike', 'Sample')
+ON CONFLICT (id) DO NOTHING;
+
+INSERT INTO part_specs (part_id, name, value, key, numeric_value, unit)
+VALUES
+    ('1', 'Speed', '12', 'speeds', 12, 'speeds'),
+    ('1', 'Range', '10-51T', 'cassette_range', 51, 'teeth'),
+    ('1', 'Weight', '460g', 'weight', 460, 'g'),
+    ('2', 'Speed', '12', 'speeds', 12, 'speeds'),
+    ('2', 'Cage Length', 'Long', 'cage_length', NULL, NULL),
+    ('2', 'Weight', '290g', 'weight', 290, 'g'),
+    ('3', 'Travel', '140mm', 'travel', 140, 'mm'),
+    ('3', 'Wheel Size', '29"', 'wheel_size', 29, 'in'),
+    ('3', 'Axle', '15x110mm Boost', 'axle', NULL, NULL)
+ON CONFLICT ON CONSTRAINT unique_part_spec DO NOTHING;
+
+INSERT INTO part_images (part_id, url, position)
+SELECT v.part_id, v.url, v.position
+FROM (VALUES
+    ('1', 'https://example.com/images/shimano-xt-cassette-1.jpg', 0),
+    ('1', 'https://example.com/images/shimano-xt-cassette-2.jpg', 1),
+    ('2', 'https://example.com/images/sram-gx-eagle-1.jpg', 0),
+    ('3', 'https://example.com/images/rockshox-pike-1.jpg', 0),
+    ('3', 'https://example.com/images/rockshox-pike-2.jpg', 1),
+    ('3', 'https://example.com/images/rockshox-pike-3.jpg', 2)
+) AS v (part_id, url, position)
+WHERE NOT EXISTS (SELECT 1 FROM part_images i WHERE i.part_id = v.part_id AND i.url = v.url);
+
+-- Price history for the last two months of parts without one
+INSERT INTO price_observations (part_id, price, msrp, currency, in_stock, observed_at)
+SELECT v.part_id, v.price, v.msrp, v.currency, v.in_stock, NOW() - v.age
+FROM (VALUES
+    ('1', 179.99, 179.99, 'USD', TRUE, INTERVAL '60 days'),
+    ('1', 169.99, 179.99, 'USD', TRUE, INTERVAL '21 days'),
+    ('1', 159.99, 179.99, 'USD', TRUE, INTERVAL '3 days'),
+    ('2', 149.99, 149.99, 'USD', FALSE, INTERVAL '45 days'),
+    ('2', 129.99, 149.99, 'USD', TRUE, INTERVAL '10 days'),
+    ('3', 899.99, 949.99, 'USD', FALSE, INTERVAL '30 days')
+) AS v (part_id, price, msrp, currency, in_stock, age)
+WHERE NOT EXISTS (SELECT 1 FROM price_observations o WHERE o.part_id = v.part_id);
@@ -7,19 +7,29 @@ function HomePage() {
   const [parts, setParts] = useState([]);
   const [loading, setLoading] = useState(true);
   const [error, setError] = useState(null);
+  const [didYouMean, setDidYouMean] = useState('');
+  const [category, setCategory] = useState('');
+  const [query, setQuery] = useState('');
+  const [filters, setFilters] = useState({});
+  const [facets, setFacets] = useState([]);
 
   useEffect(() => {
     // Load initial results
     fetchParts();
   }, []);
 
-  const fetchParts = async (query = '', category = '') => {
+  const fetchParts = async (query = '', category = '', filters = {}) => {
     setLoading(true);
     setError(null);
 
     try {
-      const data = await searchParts(query, category);
-      setParts(data);
+      const data = await searchParts(query, category, { ...filters, facets: 'brand,in_stock,price' });
+      setParts(data.parts);
+      setDidYouMean(data.did_you_mean || '');
+      setFacets(data.facets || []);
+      setQuery(query);
+      setCategory(category);
+      setFilters(filters);
     } catch (err) {
       setError(err.message || 'Failed to fetch parts');
     } finally {
@@ -31,12 +41,56 @@ function HomePage() {
     fetchParts(query, category);
   };
 
+  // Narrow the results to a facet value, or widen them again if it is
+  // already selected
+  const refine = (facet, value) => {
+    let refinement = { [facet]: value.value };
+    if (facet === 'price') {
+      refinement = { price_min: value.min, price_max: value.max };
+    }
+    const selected = Object.entries(refinement).every(([name, v]) => filters[name] === v);
+    const next = { ...filters };
+    Object.entries(refinement).forEach(([name, v]) => {
+      if (selected || v === undefined) delete next[name];
+      else next[name] = v;
+    });
+    fetchParts(query, category, next);
+  };
+
   return (
     <>
       <SearchForm onSearch={handleSearch} />
 
       <section id="results-section" className="mb-8">
         <h2 className="text-xl font-bold mb-4">Results</h2>
+        {didYouMean && (
+          <p className="mb-4">
+            Did you mean{' '}
+            <button
+              type="button"
+              onClick={() => fetchParts(didYouMean, category)}
+              className="text-green-700 font-semibold underline"
+            >
+              {didYouMean}
+            </button>
+            ?
+          </p>
+        )}
+        {facets.map((facet) => facet.values.length > 0 && (
+          <div key={facet.name} className="mb-2 flex flex-wrap gap-2 text-sm">
+            <span className="font-semibold">{facet.name.replace('_', ' ')}:</span>
+            {facet.values.map((value) => (
+              <button
+                key={value.value}
+                type="button"
+                onClick={() => refine(facet.name, value)}
+                className="text-green-700 underline"
+              >
+                {value.value} ({value.count})
+              </button>
+            ))}
+          </div>
+        ))}
         <PartsList parts={parts} loading={loading} error={error} />
       </section>
     </>
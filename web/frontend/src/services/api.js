@@ -10,11 +10,16 @@ const api = axios.create({
   },
 });
 
-export const searchParts = async (query = '', category = '') => {
+// Filters are further search parameters such as price_max, in_stock, sort
+// or spec values like speed
+export const searchParts = async (query = '', category = '', filters = {}) => {
   try {
     const params = new URLSearchParams();
     if (query) params.append('q', query);
     if (category) params.append('category', category);
+    Object.entries(filters).forEach(([name, value]) => {
+      if (value !== '' && value !== null && value !== undefined) params.append(name, value);
+    });
 
     const response = await api.get(`/parts/search?${params.toString()}`);
     return response.data;
@@ -32,6 +37,52 @@ export const getPartById = async (partId) => {
   }
 };
 
+export const getPriceHistory = async (partId, days = 90) => {
+  try {
+    const response = await api.get(`/parts/${partId}/price-history`, {
+      params: { days },
+    });
+    return response.data;
+  } catch (error) {
+    throw handleError(error);
+  }
+};
+
+export const getAlerts = async (partId) => {
+  try {
+    const response = await api.get(`/parts/${partId}/alerts`);
+    return response.data;
+  } catch (error) {
+    throw handleError(error);
+  }
+};
+
+export const createAlert = async (partId, alert) => {
+  try {
+    const response = await api.post(`/parts/${partId}/alerts`, alert);
+    return response.data;
+  } catch (error) {
+    throw handleError(error);
+  }
+};
+
+export const deleteAlert = async (alertId) => {
+  try {
+    await api.delete(`/alerts/${alertId}`);
+  } catch (error) {
+    throw handleError(error);
+  }
+};
+
+export const getCategories = async () => {
+  try {
+    const response = await api.get('/categories');
+    return response.data;
+  } catch (error) {
+    throw handleError(error);
+  }
+};
+
 const handleError = (error) => {
   if (error.response) {
     // The request was made and the server responded with a status code
@@ -62,6 +113,11 @@ const handleError = (error) => {
 const apiService = {
   searchParts,
   getPartById,
+  getPriceHistory,
+  getAlerts,
+  createAlert,
+  deleteAlert,
+  getCategories,
 };
 
 export default apiService;